@@ -0,0 +1,240 @@
+package carbonintensity
+
+import (
+	"container/list"
+	"encoding/json"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// How long after the end of a range its statistics are treated as final, by when the actual values they are calculated from
+// will have been published
+const statisticsSettleTime = 24 * time.Hour
+
+// DefaultCacheMaxEntries is the maximum number of entries held by a ResponseCache returned by NewResponseCache
+const DefaultCacheMaxEntries = 1000
+
+// ResponseCache is an in-memory cache of responses from the API, see WithCache
+//
+// Responses covering periods which have all ended, and which have actual values for all periods where the API provides them,
+// won't change so are cached indefinitely, as are statistics for ranges which ended over 24 hours ago (by when the actual values
+// they are calculated from have been published). All other responses (current values, forecasts, periods still waiting for actual
+// values, recent statistics, and anything without periods such as intensity factors) expire at the end of the current settlement
+// period, which is when the API publishes new data.
+//
+// Expired entries are removed whenever a response is added, and once the cache is full the least recently used entry is
+// removed to make room. Times part way through a settlement period in resources (e.g. from time.Now()) are treated as the same
+// when they are in the same period, so repeatedly asking for e.g. the next 24 hours from now is served from cache.
+//
+// A ResponseCache is safe for concurrent use, so can be shared by many goroutines and many APIHandlers.
+type ResponseCache struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	maxEntries int
+	// Entries in order of use, most recent first
+	recent *list.List
+	hits   uint64
+	misses uint64
+	now    func() time.Time
+}
+
+type cacheEntry struct {
+	key  string
+	body []byte
+	// Zero if the entry never expires
+	expires time.Time
+}
+
+// CacheStats gives counts of cache hits and misses, and the number of entries in the cache
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+// NewResponseCache returns an empty ResponseCache holding up to DefaultCacheMaxEntries entries
+func NewResponseCache() *ResponseCache {
+	return NewResponseCacheWithLimit(DefaultCacheMaxEntries)
+}
+
+// NewResponseCacheWithLimit returns an empty ResponseCache holding up to maxEntries entries (at least 1)
+func NewResponseCacheWithLimit(maxEntries int) *ResponseCache {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+
+	return &ResponseCache{
+		entries:    make(map[string]*list.Element),
+		maxEntries: maxEntries,
+		recent:     list.New(),
+		now:        time.Now,
+	}
+}
+
+// WithCache makes the APIHandler serve repeated requests from cache where possible
+func WithCache(cache *ResponseCache) Option {
+	return func(ah *APIHandler) {
+		ah.cache = cache
+	}
+}
+
+// Stats returns the number of hits and misses since the cache was created, and the current number of entries
+func (rc *ResponseCache) Stats() CacheStats {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return CacheStats{
+		Hits:    rc.hits,
+		Misses:  rc.misses,
+		Entries: len(rc.entries),
+	}
+}
+
+// Clear removes all entries from the cache. The hit and miss counts are not reset.
+func (rc *ResponseCache) Clear() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.entries = make(map[string]*list.Element)
+	rc.recent.Init()
+}
+
+func (rc *ResponseCache) get(key string) ([]byte, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	element, ok := rc.entries[key]
+	if ok && rc.expired(element.Value.(*cacheEntry), rc.now()) {
+		rc.remove(element)
+		ok = false
+	}
+
+	if !ok {
+		rc.misses++
+		return nil, false
+	}
+
+	rc.hits++
+	rc.recent.MoveToFront(element)
+	return element.Value.(*cacheEntry).body, true
+}
+
+func (rc *ResponseCache) put(key string, body []byte) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	now := rc.now()
+	for element := rc.recent.Front(); element != nil; {
+		next := element.Next()
+		if rc.expired(element.Value.(*cacheEntry), now) {
+			rc.remove(element)
+		}
+		element = next
+	}
+
+	if element, ok := rc.entries[key]; ok {
+		rc.remove(element)
+	}
+
+	for len(rc.entries) >= rc.maxEntries {
+		rc.remove(rc.recent.Back())
+	}
+
+	rc.entries[key] = rc.recent.PushFront(&cacheEntry{
+		key:     key,
+		body:    body,
+		expires: cacheExpiry(now, body),
+	})
+}
+
+func (rc *ResponseCache) expired(entry *cacheEntry, now time.Time) bool {
+	return !entry.expires.IsZero() && !now.Before(entry.expires)
+}
+
+func (rc *ResponseCache) remove(element *list.Element) {
+	delete(rc.entries, element.Value.(*cacheEntry).key)
+	rc.recent.Remove(element)
+}
+
+// resourceTimeRegexp matches the times in resources, which are formatted with natGridTimeFormat
+var resourceTimeRegexp = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})`)
+
+// cacheKey returns the key under which responses for resource from the server at serverAddress are cached
+//
+// The API gives the same response for any time part way through a settlement period, so those times are replaced by the start
+// of the period with a "+" appended. Times at the start of a period are left alone, as the API includes the period ending at them.
+func cacheKey(serverAddress string, resource string) string {
+	return serverAddress + resourceTimeRegexp.ReplaceAllStringFunc(resource, func(formatted string) string {
+		parsed, err := time.Parse(natGridTimeFormat, formatted)
+		if err != nil {
+			return formatted
+		}
+
+		periodStart := parsed.Truncate(settlementPeriodDuration)
+		if periodStart.Equal(parsed) {
+			return formatted
+		}
+
+		return periodStart.Format(natGridTimeFormat) + "+"
+	})
+}
+
+// cacheExpiry returns when a response body received at now should expire, or the zero time if it never should
+func cacheExpiry(now time.Time, body []byte) time.Time {
+	currentPeriodStart := now.Truncate(settlementPeriodDuration)
+	nextPeriodStart := currentPeriodStart.Add(settlementPeriodDuration)
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return nextPeriodStart
+	}
+
+	var latestTo time.Time
+	awaitingActual := false
+	statistics := false
+	scanPeriods(decoded, &latestTo, &awaitingActual, &statistics)
+
+	if latestTo.IsZero() || latestTo.After(currentPeriodStart) || awaitingActual {
+		return nextPeriodStart
+	}
+
+	if statistics && latestTo.Add(statisticsSettleTime).After(now) {
+		return nextPeriodStart
+	}
+
+	return time.Time{}
+}
+
+// scanPeriods walks decoded JSON finding the latest "to" time of any period,
+// whether any period has an "actual" intensity which hasn't been published yet (is null),
+// and whether any period has statistics (an "average" intensity) calculated from actual values.
+// Resources which never provide actual values (regional, statistics) omit "actual" entirely.
+func scanPeriods(decoded interface{}, latestTo *time.Time, awaitingActual *bool, statistics *bool) {
+	switch value := decoded.(type) {
+	case map[string]interface{}:
+		if to, ok := value["to"].(string); ok {
+			if toTime, err := time.Parse(natGridTimeFormat, to); err == nil && toTime.After(*latestTo) {
+				*latestTo = toTime
+			}
+		}
+
+		if intensity, ok := value["intensity"].(map[string]interface{}); ok {
+			if actual, ok := intensity["actual"]; ok && actual == nil {
+				*awaitingActual = true
+			}
+
+			if _, ok := intensity["average"]; ok {
+				*statistics = true
+			}
+		}
+
+		for _, child := range value {
+			scanPeriods(child, latestTo, awaitingActual, statistics)
+		}
+	case []interface{}:
+		for _, child := range value {
+			scanPeriods(child, latestTo, awaitingActual, statistics)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package carbonintensity
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheExpiry(t *testing.T) {
+	now := time.Date(2018, 1, 20, 12, 10, 0, 0, time.UTC)
+	nextPeriodStart := time.Date(2018, 1, 20, 12, 30, 0, 0, time.UTC)
+
+	// Current period
+	assert.Equal(t, nextPeriodStart, cacheExpiry(now, []byte(
+		`{"data":[{"from":"2018-01-20T12:00Z","to":"2018-01-20T12:30Z","intensity":{"forecast":266,"actual":null,"index":"moderate"}}]}`)))
+
+	// Past periods, all with actuals
+	assert.Equal(t, time.Time{}, cacheExpiry(now, []byte(
+		`{"data":[{"from":"2018-01-20T11:00Z","to":"2018-01-20T11:30Z","intensity":{"forecast":266,"actual":263,"index":"moderate"}},
+			{"from":"2018-01-20T11:30Z","to":"2018-01-20T12:00Z","intensity":{"forecast":266,"actual":270,"index":"moderate"}}]}`)))
+
+	// Past periods, still waiting for an actual
+	assert.Equal(t, nextPeriodStart, cacheExpiry(now, []byte(
+		`{"data":[{"from":"2018-01-20T11:00Z","to":"2018-01-20T11:30Z","intensity":{"forecast":266,"actual":263,"index":"moderate"}},
+			{"from":"2018-01-20T11:30Z","to":"2018-01-20T12:00Z","intensity":{"forecast":266,"actual":null,"index":"moderate"}}]}`)))
+
+	// Past periods for resources which never have actuals
+	assert.Equal(t, time.Time{}, cacheExpiry(now.AddDate(0, 6, 0), []byte(testRegionalAllResponse)))
+
+	// Statistics, which are only final a day after the range ends, once the actuals they are calculated from are published
+	statistics := []byte(
+		`{"data":[{"from":"2018-01-13T12:00Z","to":"2018-01-20T12:00Z","intensity":{"max":320,"average":266,"min":180,"index":"moderate"}}]}`)
+	assert.Equal(t, nextPeriodStart, cacheExpiry(now, statistics))
+	assert.Equal(t, nextPeriodStart.Add(23*time.Hour), cacheExpiry(now.Add(23*time.Hour), statistics))
+	assert.Equal(t, time.Time{}, cacheExpiry(now.Add(24*time.Hour), statistics))
+
+	// Forecasts
+	assert.Equal(t, nextPeriodStart, cacheExpiry(now, []byte(
+		`{"data":[{"from":"2018-01-20T12:30Z","to":"2018-01-20T13:00Z","intensity":{"forecast":266,"actual":null,"index":"moderate"}}]}`)))
+
+	// No periods
+	assert.Equal(t, nextPeriodStart, cacheExpiry(now, []byte(`{"data":[{"Biomass":120,"Coal":937}]}`)))
+}
+
+func TestCache(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/intensity":
+			w.Write([]byte(`{"data":[{"from":"2018-01-20T12:00Z","to":"2018-01-20T12:30Z","intensity":{"forecast":266,"actual":null,"index":"moderate"}}]}`))
+		case "/intensity/2018-01-19T12:00Z/2018-01-19T13:00Z":
+			w.Write([]byte(`{"data":[{"from":"2018-01-19T12:00Z","to":"2018-01-19T12:30Z","intensity":{"forecast":266,"actual":263,"index":"moderate"}},
+				{"from":"2018-01-19T12:30Z","to":"2018-01-19T13:00Z","intensity":{"forecast":266,"actual":270,"index":"moderate"}}]}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Date(2018, 1, 20, 12, 10, 0, 0, time.UTC)
+	cache := NewResponseCache()
+	cache.now = func() time.Time { return now }
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithCache(cache))
+
+	for i := 0; i < 3; i++ {
+		intensity, err := handler.GetCurrentIntensity()
+		assert.NoError(t, err)
+		assert.Equal(t, 266, intensity.Forecast)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 1, Entries: 1}, cache.Stats())
+
+	// Historical data
+	from := time.Date(2018, 1, 19, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		intensityArr, err := handler.GetIntensityBetween(from, from.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(intensityArr))
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	assert.Equal(t, CacheStats{Hits: 4, Misses: 2, Entries: 2}, cache.Stats())
+
+	// Errors aren't cached
+	for i := 0; i < 2; i++ {
+		_, err := handler.GetIntensityFactors()
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+
+	// Move on to the next settlement period, the current intensity should expire but not the historical data
+	now = now.Add(20 * time.Minute)
+
+	_, err := handler.GetCurrentIntensity()
+	assert.NoError(t, err)
+	_, err = handler.GetIntensityBetween(from, from.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&requests))
+
+	// Handlers for other servers sharing the cache shouldn't get each other's responses
+	otherHandler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL+"/"), WithCache(cache))
+	_, err = otherHandler.GetCurrentIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&requests))
+
+	cache.Clear()
+	assert.Equal(t, 0, cache.Stats().Entries)
+	_, err = handler.GetCurrentIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(6), atomic.LoadInt32(&requests))
+}
+
+func TestCacheKey(t *testing.T) {
+	server := "https://api.example.com"
+
+	// Times part way through a period are the same as each other, but not as the start of the period
+	assert.Equal(t, server+"/intensity/2018-01-20T12:00Z+/fw24h", cacheKey(server, "/intensity/2018-01-20T12:10Z/fw24h"))
+	assert.Equal(t, cacheKey(server, "/intensity/2018-01-20T12:10Z/fw24h"), cacheKey(server, "/intensity/2018-01-20T12:29Z/fw24h"))
+	assert.Equal(t, server+"/intensity/2018-01-20T12:30Z/fw24h", cacheKey(server, "/intensity/2018-01-20T12:30Z/fw24h"))
+	assert.Equal(t, server+"/intensity/2018-01-20T12:00Z+/2018-01-20T14:30Z+", cacheKey(server,
+		"/intensity/2018-01-20T12:05Z/2018-01-20T14:45Z"))
+	assert.Equal(t, server+"/regional/intensity/2018-05-15T12:00+01:00+/fw24h/postcode/RG10", cacheKey(server,
+		"/regional/intensity/2018-05-15T12:15+01:00/fw24h/postcode/RG10"))
+	assert.Equal(t, server+"/intensity/date/2018-01-20/3", cacheKey(server, "/intensity/date/2018-01-20/3"))
+}
+
+func TestCacheEviction(t *testing.T) {
+	now := time.Date(2018, 1, 20, 12, 10, 0, 0, time.UTC)
+	cache := NewResponseCacheWithLimit(3)
+	cache.now = func() time.Time { return now }
+
+	current := []byte(`{"data":[{"from":"2018-01-20T12:00Z","to":"2018-01-20T12:30Z","intensity":{"forecast":266,"actual":null,"index":"moderate"}}]}`)
+	historical := []byte(`{"data":[{"from":"2018-01-19T12:00Z","to":"2018-01-19T12:30Z","intensity":{"forecast":266,"actual":263,"index":"moderate"}}]}`)
+
+	// Polling with keys which are never asked for again doesn't grow the cache, as expired entries are removed
+	cache.put("historical", historical)
+	for poll := 0; poll < 10; poll++ {
+		cache.put(fmt.Sprintf("poll-%d", poll), current)
+		now = now.Add(30 * time.Minute)
+	}
+	assert.Equal(t, 2, cache.Stats().Entries)
+
+	_, ok := cache.get("historical")
+	assert.True(t, ok)
+	_, ok = cache.get("poll-8")
+	assert.False(t, ok)
+
+	// Once full, the least recently used entry is removed
+	cache.put("a", historical)
+	cache.put("b", historical)
+	_, ok = cache.get("historical")
+	assert.True(t, ok)
+	cache.put("c", historical)
+	assert.Equal(t, 3, cache.Stats().Entries)
+
+	_, ok = cache.get("a")
+	assert.False(t, ok)
+	for _, key := range []string{"historical", "b", "c"} {
+		_, ok = cache.get(key)
+		assert.True(t, ok, key)
+	}
+}
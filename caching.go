@@ -0,0 +1,286 @@
+package carbonintensity
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CachingHandler fetches data from the API with an APIHandler, keeping it in a Store so that it needn't be fetched again
+//
+// Only data which won't change is stored, and served from the store; Intensity for settlement periods which have ended and have
+// an Actual value, GenerationMix for settlement periods which have ended, and Statistics for ranges which ended over 24 hours ago.
+// Everything else is fetched from the API every time.
+//
+// A CachingHandler is safe for concurrent use, as long as its Store is.
+type CachingHandler struct {
+	handler *APIHandler
+	store   Store
+	now     func() time.Time
+}
+
+// NewCachingHandler returns a CachingHandler which fetches data with handler, keeping it in store
+func NewCachingHandler(handler *APIHandler, store Store) *CachingHandler {
+	return &CachingHandler{handler: handler, store: store, now: time.Now}
+}
+
+// periodStartsBetween returns the start of every settlement period which is at least partly between from and to
+func periodStartsBetween(from time.Time, to time.Time) []time.Time {
+	var starts []time.Time
+	for start := from.UTC().Truncate(settlementPeriodDuration); start.Before(to); start = start.Add(settlementPeriodDuration) {
+		starts = append(starts, start)
+	}
+
+	return starts
+}
+
+// missingPeriodsError returns an error wrapping ErrMissingPeriods for the settlement periods starting at missing
+func missingPeriodsError(missing []time.Time) error {
+	return fmt.Errorf("%w; no data for %d periods, the first starting at %s", ErrMissingPeriods, len(missing),
+		missing[0].Format(natGridTimeFormat))
+}
+
+// GetIntensityBetween returns an array of Intensity objects, for every 30 minute settlement period which is at least partly
+// between from and to, in order of From
+//
+// Periods which are complete in the store are served from it, and the rest are fetched with GetIntensityBetweenChunked, one
+// request for each run of consecutive periods, so the range isn't limited. Periods the API doesn't have any data for are left out,
+// and if any are, the result is returned along with an error wrapping ErrMissingPeriods (or a *PartialResponseError, if
+// WithLenientDecoding is used and entries were skipped).
+func (ch *CachingHandler) GetIntensityBetween(from time.Time, to time.Time) ([]*Intensity, error) {
+	return ch.GetIntensityBetweenContext(context.Background(), from, to)
+}
+
+// GetIntensityBetweenContext is the same as GetIntensityBetween, but the requests are made with the context ctx
+func (ch *CachingHandler) GetIntensityBetweenContext(ctx context.Context, from time.Time, to time.Time) ([]*Intensity, error) {
+	if err := checkChunkRange(from, to); err != nil {
+		return nil, err
+	}
+
+	starts := periodStartsBetween(from, to)
+	// Including the period ending at starts[0], which the API returns too, so it isn't stored again
+	stored, err := ch.store.GetIntensity(starts[0].Add(-settlementPeriodDuration), to)
+	if err != nil {
+		return nil, err
+	}
+
+	now := ch.now()
+	final := func(entry *Intensity) bool { return !entry.To.After(now) && entry.HasActual() }
+
+	byStart := make(map[time.Time]*Intensity)
+	for _, entry := range stored {
+		if final(entry) {
+			byStart[entry.From.UTC()] = entry
+		}
+	}
+
+	var warning error
+	for _, run := range missingRuns(starts, func(from time.Time) bool { return byStart[from] != nil }) {
+		entries, err := ch.handler.GetIntensityBetweenChunkedContext(ctx, run.from, run.to)
+		if err != nil && !isChunkWarning(err) {
+			return nil, err
+		}
+
+		if err != nil && warning == nil {
+			warning = err
+		}
+
+		var finalEntries []*Intensity
+		for _, entry := range entries {
+			if byStart[entry.From.UTC()] != nil {
+				continue
+			}
+
+			byStart[entry.From.UTC()] = entry
+			if final(entry) {
+				finalEntries = append(finalEntries, entry)
+			}
+		}
+
+		if err := ch.store.PutIntensity(finalEntries); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []*Intensity
+	var missing []time.Time
+	for _, start := range starts {
+		if entry := byStart[start]; entry != nil {
+			result = append(result, entry)
+		} else {
+			missing = append(missing, start)
+		}
+	}
+
+	if warning == nil && len(missing) > 0 {
+		warning = missingPeriodsError(missing)
+	}
+
+	return result, warning
+}
+
+// GetGenerationMixBetween returns an array of GenerationMix objects, for every 30 minute settlement period which is at least partly
+// between from and to, in order of From
+//
+// Periods which are complete in the store are served from it, and the rest are fetched with GetGenerationMixBetween, one request
+// for each run of consecutive periods. Unlike GetIntensityBetween the runs aren't chunked, so each must be 30 days or less.
+// Periods the API doesn't have any data for are left out, and if any are, the result is returned along with an error
+// wrapping ErrMissingPeriods.
+func (ch *CachingHandler) GetGenerationMixBetween(from time.Time, to time.Time) ([]*GenerationMix, error) {
+	return ch.GetGenerationMixBetweenContext(context.Background(), from, to)
+}
+
+// GetGenerationMixBetweenContext is the same as GetGenerationMixBetween, but the requests are made with the context ctx
+func (ch *CachingHandler) GetGenerationMixBetweenContext(ctx context.Context, from time.Time, to time.Time) ([]*GenerationMix, error) {
+	if err := checkChunkRange(from, to); err != nil {
+		return nil, err
+	}
+
+	starts := periodStartsBetween(from, to)
+	// Including the period ending at starts[0], which the API returns too, so it isn't stored again
+	stored, err := ch.store.GetGenerationMix(starts[0].Add(-settlementPeriodDuration), to)
+	if err != nil {
+		return nil, err
+	}
+
+	now := ch.now()
+	final := func(entry *GenerationMix) bool { return !entry.To.After(now) }
+
+	byStart := make(map[time.Time]*GenerationMix)
+	for _, entry := range stored {
+		if final(entry) {
+			byStart[entry.From.UTC()] = entry
+		}
+	}
+
+	for _, run := range missingRuns(starts, func(from time.Time) bool { return byStart[from] != nil }) {
+		entries, err := ch.handler.GetGenerationMixBetweenContext(ctx, run.from, run.to)
+		if err != nil {
+			return nil, err
+		}
+
+		var finalEntries []*GenerationMix
+		for _, entry := range entries {
+			if byStart[entry.From.UTC()] != nil {
+				continue
+			}
+
+			byStart[entry.From.UTC()] = entry
+			if final(entry) {
+				finalEntries = append(finalEntries, entry)
+			}
+		}
+
+		if err := ch.store.PutGenerationMix(finalEntries); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []*GenerationMix
+	var missing []time.Time
+	for _, start := range starts {
+		if entry := byStart[start]; entry != nil {
+			result = append(result, entry)
+		} else {
+			missing = append(missing, start)
+		}
+	}
+
+	if len(missing) > 0 {
+		return result, missingPeriodsError(missing)
+	}
+
+	return result, nil
+}
+
+// GetStatistics returns a Statistics object giving carbon intensity statistics for the period between from and to, as
+// GetStatistics of APIHandler does. It is served from the store if it has been fetched before and is final.
+func (ch *CachingHandler) GetStatistics(from time.Time, to time.Time) (*Statistics, error) {
+	return ch.GetStatisticsContext(context.Background(), from, to)
+}
+
+// GetStatisticsContext is the same as GetStatistics, but the request is made with the context ctx
+func (ch *CachingHandler) GetStatisticsContext(ctx context.Context, from time.Time, to time.Time) (*Statistics, error) {
+	stored, err := ch.storedStatistics(from, to, to.Sub(from))
+	if err != nil {
+		return nil, err
+	}
+
+	if stored != nil {
+		return stored[0], nil
+	}
+
+	statistics, err := ch.handler.GetStatisticsContext(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return statistics, ch.putStatistics([]*Statistics{statistics})
+}
+
+// GetStatisticsInBlocks returns an array of Statistics objects for the period between from and to, in blocks of blockSize,
+// as GetStatisticsInBlocks of APIHandler does. They are served from the store if they have all been fetched before and are final.
+func (ch *CachingHandler) GetStatisticsInBlocks(from time.Time, to time.Time, blockSize time.Duration) ([]*Statistics, error) {
+	return ch.GetStatisticsInBlocksContext(context.Background(), from, to, blockSize)
+}
+
+// GetStatisticsInBlocksContext is the same as GetStatisticsInBlocks, but the request is made with the context ctx
+func (ch *CachingHandler) GetStatisticsInBlocksContext(ctx context.Context, from time.Time, to time.Time, blockSize time.Duration) ([]*Statistics, error) {
+	// The API only takes whole hours, so the blocks stored are those of the rounded down size
+	stored, err := ch.storedStatistics(from, to, blockSize.Truncate(time.Hour))
+	if err != nil || stored != nil {
+		return stored, err
+	}
+
+	statistics, err := ch.handler.GetStatisticsInBlocksContext(ctx, from, to, blockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return statistics, ch.putStatistics(statistics)
+}
+
+// storedStatistics returns the blocks of blockSize from from to to from the store, or nil if any are missing or not final
+func (ch *CachingHandler) storedStatistics(from time.Time, to time.Time, blockSize time.Duration) ([]*Statistics, error) {
+	if blockSize <= 0 || !from.Before(to) || to.Add(statisticsSettleTime).After(ch.now()) {
+		return nil, nil
+	}
+
+	stored, err := ch.store.GetStatistics(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[storeKey]*Statistics)
+	for _, entry := range stored {
+		byKey[newStoreKey(entry.From, entry.To)] = entry
+	}
+
+	var blocks []*Statistics
+	for _, block := range splitRange(from, to, blockSize) {
+		entry := byKey[newStoreKey(block.from, block.to)]
+		if entry == nil {
+			return nil, nil
+		}
+
+		blocks = append(blocks, entry)
+	}
+
+	return blocks, nil
+}
+
+// putStatistics stores those of entries which are final
+func (ch *CachingHandler) putStatistics(entries []*Statistics) error {
+	var final []*Statistics
+	for _, entry := range entries {
+		if !entry.To.Add(statisticsSettleTime).After(ch.now()) {
+			final = append(final, entry)
+		}
+	}
+
+	if len(final) == 0 {
+		return nil
+	}
+
+	return ch.store.PutStatistics(final)
+}
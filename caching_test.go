@@ -0,0 +1,184 @@
+package carbonintensity
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newCachingTestServer returns a test server giving periods starting before actualsUntil an actual intensity of their hour,
+// and leaving out periods starting at missing
+func newCachingTestServer(actualsUntil time.Time, missing time.Time) *testPeriodServer {
+	return startTestPeriodServer(&testPeriodServer{
+		actual:  func(from time.Time) (int, bool) { return from.Hour(), from.Before(actualsUntil) },
+		missing: missing,
+	})
+}
+
+func TestCachingHandlerIntensity(t *testing.T) {
+	now := time.Date(2018, 1, 20, 13, 10, 0, 0, time.UTC)
+	server := newCachingTestServer(time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC), time.Time{})
+	defer server.Close()
+
+	store := NewMemoryStore()
+	handler := NewCachingHandler(NewCarbonIntensityAPIHandler(WithBaseURL(server.URL)), store)
+	handler.now = func() time.Time { return now }
+
+	from := time.Date(2018, 1, 20, 10, 0, 0, 0, time.UTC)
+	entries, err := handler.GetIntensityBetween(from, from.Add(2*time.Hour))
+	assert.NoError(t, err)
+	if assert.Equal(t, 4, len(entries)) {
+		assert.Equal(t, from, entries[0].From)
+		assert.Equal(t, 11, entries[3].Actual)
+	}
+	assert.Equal(t, []string{"/intensity/2018-01-20T10:00Z/2018-01-20T12:00Z"}, server.paths())
+
+	// Everything is now served from the store, even from a time within a period
+	entries, err = handler.GetIntensityBetween(from.Add(10*time.Minute), from.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(entries))
+	assert.Equal(t, 1, server.requestCount())
+
+	// Only the missing runs are fetched (the period ending at 10:00 came with the first request), and periods without actuals
+	// are fetched every time
+	entries, err = handler.GetIntensityBetween(from.Add(-time.Hour), from.Add(4*time.Hour))
+	assert.NoError(t, err)
+	if assert.Equal(t, 10, len(entries)) {
+		assert.Equal(t, from.Add(-time.Hour), entries[0].From)
+		assert.Equal(t, -1, entries[9].Actual)
+	}
+	assert.Equal(t, []string{"/intensity/2018-01-20T09:00Z/2018-01-20T09:30Z", "/intensity/2018-01-20T12:00Z/2018-01-20T14:00Z"},
+		server.paths()[1:])
+
+	_, err = handler.GetIntensityBetween(from.Add(-time.Hour), from.Add(4*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/intensity/2018-01-20T12:00Z/2018-01-20T14:00Z"}, server.paths()[3:])
+
+	// Only complete periods are stored
+	stored, err := store.GetIntensity(from.Add(-time.Hour), from.Add(4*time.Hour))
+	assert.NoError(t, err)
+	if assert.Equal(t, 6, len(stored)) {
+		assert.Equal(t, from.Add(90*time.Minute), stored[5].From)
+	}
+
+	_, err = handler.GetIntensityBetween(from, from)
+	assert.True(t, errors.Is(err, ErrInvalidRange))
+}
+
+func TestCachingHandlerMissingPeriods(t *testing.T) {
+	missing := time.Date(2018, 1, 20, 10, 0, 0, 0, time.UTC)
+	server := newCachingTestServer(time.Date(2018, 1, 21, 0, 0, 0, 0, time.UTC), missing)
+	defer server.Close()
+
+	handler := NewCachingHandler(NewCarbonIntensityAPIHandler(WithBaseURL(server.URL)), NewMemoryStore())
+	handler.now = func() time.Time { return time.Date(2018, 1, 21, 0, 0, 0, 0, time.UTC) }
+
+	// The missing period is at the start of the range, so it isn't a gap between the entries fetched
+	entries, err := handler.GetIntensityBetween(missing, missing.Add(time.Hour))
+	assert.True(t, errors.Is(err, ErrMissingPeriods))
+	if assert.Equal(t, 1, len(entries)) {
+		assert.Equal(t, missing.Add(30*time.Minute), entries[0].From)
+	}
+
+	// The missing period is fetched again, but not the one which is stored
+	entries, err = handler.GetIntensityBetween(missing, missing.Add(time.Hour))
+	assert.True(t, errors.Is(err, ErrMissingPeriods))
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "/intensity/2018-01-20T10:00Z/2018-01-20T10:30Z", server.paths()[1])
+
+	mixes, err := handler.GetGenerationMixBetween(missing.Add(-time.Hour), missing.Add(time.Hour))
+	assert.True(t, errors.Is(err, ErrMissingPeriods))
+	assert.Equal(t, 3, len(mixes))
+}
+
+func TestCachingHandlerGenerationMix(t *testing.T) {
+	now := time.Date(2018, 1, 20, 12, 10, 0, 0, time.UTC)
+	server := newCachingTestServer(now, time.Time{})
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "caching")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileStore(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	handler := NewCachingHandler(NewCarbonIntensityAPIHandler(WithBaseURL(server.URL)), store)
+	handler.now = func() time.Time { return now }
+
+	from := time.Date(2018, 1, 20, 11, 0, 0, 0, time.UTC)
+	mixes, err := handler.GetGenerationMixBetween(from, from.Add(90*time.Minute))
+	assert.NoError(t, err)
+	if assert.Equal(t, 3, len(mixes)) {
+		assert.Equal(t, 11.0, mixes[1].Wind)
+		assert.Equal(t, 12.0, mixes[2].Wind)
+	}
+
+	// The period in progress is fetched again
+	mixes, err = handler.GetGenerationMixBetween(from, from.Add(90*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(mixes))
+	assert.Equal(t, []string{"/generation/2018-01-20T11:00Z/2018-01-20T12:30Z", "/generation/2018-01-20T12:00Z/2018-01-20T12:30Z"},
+		server.paths())
+
+	// Only the periods which have ended are stored, each once however often they are fetched
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "generation-2018-01.jsonl"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(contents), "\n"))
+
+	_, err = handler.GetGenerationMixBetween(from, from.Add(90*time.Minute))
+	assert.NoError(t, err)
+	contents, err = ioutil.ReadFile(filepath.Join(dir, "generation-2018-01.jsonl"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(contents), "\n"))
+}
+
+func TestCachingHandlerStatistics(t *testing.T) {
+	now := time.Date(2018, 1, 21, 12, 0, 0, 0, time.UTC)
+	server := newCachingTestServer(now, time.Time{})
+	defer server.Close()
+
+	handler := NewCachingHandler(NewCarbonIntensityAPIHandler(WithBaseURL(server.URL)), NewMemoryStore())
+	handler.now = func() time.Time { return now }
+
+	// Ranges which ended over a day ago are stored
+	from := time.Date(2018, 1, 19, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		blocks, err := handler.GetStatisticsInBlocks(from, from.Add(24*time.Hour), 6*time.Hour)
+		assert.NoError(t, err)
+		if assert.Equal(t, 4, len(blocks)) {
+			assert.Equal(t, from.Add(18*time.Hour), blocks[3].From)
+			assert.Equal(t, 200, blocks[3].Average)
+		}
+	}
+	assert.Equal(t, 1, server.requestCount())
+
+	// Block sizes are rounded down to whole hours, as the API does
+	blocks, err := handler.GetStatisticsInBlocks(from, from.Add(24*time.Hour), 6*time.Hour+10*time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(blocks))
+	assert.Equal(t, 1, server.requestCount())
+
+	// As are single blocks, which can come from an earlier request for several
+	statistics, err := handler.GetStatistics(from.Add(6*time.Hour), from.Add(12*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 300, statistics.Max)
+	assert.Equal(t, 1, server.requestCount())
+
+	// Ranges which ended more recently aren't
+	for i := 0; i < 2; i++ {
+		_, err = handler.GetStatisticsInBlocks(from.Add(24*time.Hour), from.Add(48*time.Hour), 6*time.Hour)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 3, server.requestCount())
+}
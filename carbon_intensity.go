@@ -2,10 +2,15 @@
 package carbonintensity
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -13,19 +18,28 @@ const (
 	natGridServerAddress = "https://api.carbonintensity.org.uk"
 	natGridTimeFormat    = "2006-01-02T15:04Z07:00"
 
-	indexVeryLow  = "very low"
-	indexLow      = "low"
-	indexModerate = "moderate"
-	indexHigh     = "high"
-	indexVeryHigh = "very high"
+	// The maximum date range of the /intensity/{from}/{to} and /intensity/stats resources
+	maxDateRange = time.Hour * 24 * 30
+
+	// The largest responses (regional data for all regions over 14 days) are a few megabytes
+	maxResponseBytes = 32 * 1024 * 1024
 )
 
 // APIHandler is the struct which provides functions for querying the carbon intensity API
 type APIHandler struct {
 	serverAddress string
+	client        *http.Client
+	userAgent     string
+	retryPolicy   RetryPolicy
+	rateLimiter   *RateLimiter
+	cache         *ResponseCache
+
+	chunkConcurrency int
+	lenientDecoding  bool
 }
 
 type intensityResponse struct {
+	entryDecoder
 	entries []*Intensity
 }
 
@@ -33,37 +47,43 @@ type intensityResponse struct {
 // It represents forecast and estimated actual carbon intensity for a period of time, given by From and To
 //
 // Forecast and Actual are in units of gCO2/KWh.
-// Index is a string in the set { indexVeryLow, indexLow, indexModerate, indexHigh, indexVeryHigh }
+// Index is the API's rating of the intensity, from IndexVeryLow to IndexVeryHigh
 //
 // For future periods, Forecast will be set but Actual wont be - it will be set to -1. In this case Index will be based off the forecast
+// Use ActualValue, ForecastValue or Best rather than checking for -1.
+// When marshalled to JSON, missing values are null rather than -1.
 type Intensity struct {
 	From     time.Time
 	To       time.Time
 	Forecast int
 	Actual   int
-	Index    string
+	Index    IntensityIndex
 }
 
 type statisticsResponse struct {
+	entryDecoder
 	entries []*Statistics
 }
 
 // Statistics respresents a result from the 'national statistics' for a period of time, given by From and To
 //
 // Max Average and Min are the obvious statistical values for carbon intensity over the given period, in units of gCO2/Kwh.
-// Index is a string in the set { indexVeryLow, indexLow, indexModerate, indexHigh, indexVeryHigh }.
+// Index is the API's rating of the Average, from IndexVeryLow to IndexVeryHigh.
 // Future periods use forecast data. Past data uses actual data.
+// Any value the API doesn't give is set to -1; use MaxValue, AverageValue and MinValue rather than checking for -1.
+// When marshalled to JSON, missing values are null rather than -1.
 type Statistics struct {
 	From    time.Time
 	To      time.Time
 	Max     int
 	Average int
 	Min     int
-	Index   string
+	Index   IntensityIndex
 }
 
 // IntensityFactors represents Carbon intensity factors used for different fuel types in the carbon intensity estimations.
 // Units are gCO2/KWh (grams of CO2 per kilowatt hour).
+// When marshalled to JSON, the fuel types are named as they are by the API.
 type IntensityFactors struct {
 	Biomass          int
 	Coal             int
@@ -82,130 +102,282 @@ type IntensityFactors struct {
 }
 
 // NewCarbonIntensityAPIHandler returns an APIHandler ready to make queries of the national grid carbon intensity API server
-func NewCarbonIntensityAPIHandler() *APIHandler {
-	return newCarbonIntensityAPIHandlerInternal(natGridServerAddress)
-}
+//
+// The APIHandler can be configured by providing options, such as WithHTTPClient or WithBaseURL. Options are applied in order.
+func NewCarbonIntensityAPIHandler(options ...Option) *APIHandler {
+	ah := &APIHandler{
+		serverAddress: natGridServerAddress,
+		client:        http.DefaultClient,
+	}
 
-// Allow for a test server to be provided
-func newCarbonIntensityAPIHandlerInternal(serverAddress string) *APIHandler {
-	return &APIHandler{
-		serverAddress: serverAddress,
+	for _, option := range options {
+		option(ah)
 	}
+
+	return ah
 }
 
-func unmarshalInt(val interface{}, valIfNil int) int {
-	if val == nil {
-		return valIfNil
+// unmarshalAPIResponse unmarshals responseBytes into response, such that JSON which isn't even valid is reported as ErrUnexpectedResponse
+func unmarshalAPIResponse(responseBytes []byte, response interface{}) error {
+	err := json.Unmarshal(responseBytes, response)
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Errorf("%w; %s", ErrUnexpectedResponse, err)
 	}
 
-	return int(val.(float64))
+	return err
 }
 
 func (ir *intensityResponse) UnmarshalJSON(data []byte) error {
-	var decoded map[string]interface{}
-	if err := json.Unmarshal(data, &decoded); err != nil {
-		return err
-	}
+	return ir.decodeEntries(data, func(index int, rawEntry json.RawMessage) error {
+		var decodedEntry apiIntensityEntry
+		if err := json.Unmarshal(rawEntry, &decodedEntry); err != nil {
+			return err
+		}
 
-	if decoded["data"] == nil {
-		if decoded["error"] == nil {
-			return fmt.Errorf("Failed to unmarshal JSON; %s", string(data))
+		newEntry, err := newIntensity(&decodedEntry.apiPeriod, decodedEntry.Intensity)
+		if err != nil {
+			return err
 		}
 
-		errorMap := decoded["error"].(map[string]interface{})
-		return fmt.Errorf("API error; Code: %s Message: %s", errorMap["code"].(string), errorMap["message"].(string))
+		ir.entries = append(ir.entries, newEntry)
+		return nil
+	})
+}
+
+// getIntensityResponse returns the entries of the response from the API for resource
+func (ah *APIHandler) getIntensityResponse(ctx context.Context, resource string) ([]*Intensity, error) {
+	responseBytes, err := ah.getAPIResponse(ctx, resource)
+	if err != nil {
+		return nil, err
 	}
 
-	decodedData := decoded["data"].([]interface{})
-	ir.entries = make([]*Intensity, 0, len(decodedData))
+	response := intensityResponse{entryDecoder: entryDecoder{lenient: ah.lenientDecoding}}
+	if err := unmarshalAPIResponse(responseBytes, &response); err != nil {
+		return nil, err
+	}
 
-	for _, value := range decodedData {
-		decodedDataEntry := value.(map[string]interface{})
+	return response.entries, response.err()
+}
 
-		toTime, err := time.Parse(natGridTimeFormat, decodedDataEntry["to"].(string))
-		if err != nil {
-			return nil
+func (ah *APIHandler) getSingleIntensityResponse(ctx context.Context, resource string) (*Intensity, error) {
+	entries, err := ah.getIntensityResponse(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) != 1 {
+		return nil, fmt.Errorf("%w; unexpected number of entries (%d) for %s", ErrUnexpectedResponse, len(entries), resource)
+	}
+
+	return entries[0], nil
+}
+
+func (ie *Intensity) String() string {
+	return fmt.Sprintf("%s -> %s {forecast: %d, actual: %d, index: %s}", ie.From.Format(natGridTimeFormat),
+		ie.To.Format(natGridTimeFormat), ie.Forecast, ie.Actual, ie.Index)
+}
+
+// ForecastValue returns Forecast, and whether it is known (i.e. isn't -1)
+func (ie *Intensity) ForecastValue() (int, bool) {
+	return ie.Forecast, ie.Forecast != -1
+}
+
+// HasActual returns whether the estimated actual intensity is known, which it isn't for future periods
+func (ie *Intensity) HasActual() bool {
+	return ie.Actual != -1
+}
+
+// ActualValue returns Actual, and whether it is known (i.e. isn't -1)
+//
+// Prefer this over using Actual directly, so that the -1 of a missing value can't be accidentally used as an intensity.
+func (ie *Intensity) ActualValue() (int, bool) {
+	return ie.Actual, ie.HasActual()
+}
+
+// Best returns the best known intensity for the period; Actual if it is known, otherwise Forecast.
+// The bool result is false if neither is known.
+func (ie *Intensity) Best() (int, bool) {
+	if ie.HasActual() {
+		return ie.Actual, true
+	}
+
+	return ie.ForecastValue()
+}
+
+// getAPIResponse returns the body of a successful response from the API for resource.
+// Responses are served from the cache of the APIHandler if possible, and failed requests are retried as allowed by its retry policy.
+func (ah *APIHandler) getAPIResponse(ctx context.Context, resource string) ([]byte, error) {
+	key := cacheKey(ah.serverAddress, resource)
+	if ah.cache != nil {
+		if responseBytes, ok := ah.cache.get(key); ok {
+			return responseBytes, nil
 		}
+	}
 
-		fromTime, err := time.Parse(natGridTimeFormat, decodedDataEntry["from"].(string))
-		if err != nil {
-			return nil
+	for attempt := 1; ; attempt++ {
+		responseBytes, err := ah.doAPIRequest(ctx, resource)
+		if err == nil {
+			if ah.cache != nil {
+				ah.cache.put(key, responseBytes)
+			}
+
+			return responseBytes, nil
 		}
 
-		decodedIntensity := decodedDataEntry["intensity"].(map[string]interface{})
+		delay, retry := ah.retryPolicy.retryDelay(ctx, attempt, err)
+		if !retry {
+			return nil, err
+		}
 
-		newEntry := &Intensity{To: toTime,
-			From:     fromTime,
-			Forecast: unmarshalInt(decodedIntensity["forecast"], -1),
-			Actual:   unmarshalInt(decodedIntensity["actual"], -1),
-			Index:    decodedIntensity["index"].(string),
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
 		}
+	}
+}
 
-		ir.entries = append(ir.entries, newEntry)
+// doAPIRequest makes a single request to the API for resource, returning the body of the response if it was successful
+func (ah *APIHandler) doAPIRequest(ctx context.Context, resource string) ([]byte, error) {
+	if ah.rateLimiter != nil {
+		if err := ah.rateLimiter.Wait(ctx); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
-}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s%s", ah.serverAddress, resource), nil)
+	if err != nil {
+		return nil, err
+	}
 
-func (ie *Intensity) String() string {
-	return fmt.Sprintf("%s -> %s {forecast: %d, actual: %d, index: %s}", ie.From.Format(natGridTimeFormat),
-		ie.To.Format(natGridTimeFormat), ie.Forecast, ie.Actual, ie.Index)
-}
+	if ah.userAgent != "" {
+		req.Header.Set("User-Agent", ah.userAgent)
+	}
 
-func (ah *APIHandler) getAPIResponse(resource string) ([]byte, error) {
-	resp, err := http.Get(fmt.Sprintf("%s%s", ah.serverAddress, resource))
+	resp, err := ah.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	return ioutil.ReadAll(resp.Body)
+	// Read one byte more than the maximum so that we can tell if the body was too large
+	responseBytes, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	requestURL := req.URL.String()
+	contentType := resp.Header.Get("Content-Type")
+	isJSON := isJSONContentType(contentType)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// The API reports the details of errors in the "error" member of the response, so use that if we can
+		var apiErr *APIError
+		if isJSON && len(responseBytes) <= maxResponseBytes {
+			if _, err := decodeAPIData(responseBytes); errors.As(err, &apiErr) {
+				apiErr.StatusCode = resp.StatusCode
+				apiErr.URL = requestURL
+				apiErr.Body = truncateBody(responseBytes)
+				apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+				return nil, apiErr
+			}
+		}
+
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Code:       resp.Status,
+			Message:    http.StatusText(resp.StatusCode),
+			URL:        requestURL,
+			Body:       truncateBody(responseBytes),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	if !isJSON {
+		return nil, &ResponseError{
+			StatusCode:  resp.StatusCode,
+			ContentType: contentType,
+			URL:         requestURL,
+			Body:        truncateBody(responseBytes),
+			Reason:      "response is not JSON",
+		}
+	}
+
+	if len(responseBytes) > maxResponseBytes {
+		return nil, &ResponseError{
+			StatusCode:  resp.StatusCode,
+			ContentType: contentType,
+			URL:         requestURL,
+			Body:        truncateBody(responseBytes),
+			Reason:      fmt.Sprintf("response is larger than %d bytes", maxResponseBytes),
+		}
+	}
+
+	return responseBytes, nil
 }
 
-// GetIntensityForDay returns an array of Intensity objects, for all 30 minute settlement periods in day represented by date
-func (ah *APIHandler) GetIntensityForDay(date time.Time) ([]*Intensity, error) {
-	year, month, day := date.Date()
+// isJSONContentType returns whether contentType is a JSON media type.
+// A missing Content-Type is given the benefit of the doubt, as the JSON parsing will catch anything else.
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
 
-	responseBytes, err := ah.getAPIResponse(fmt.Sprintf("/intensity/date/%04d-%02d-%02d", year, month, day))
+	mediaType, _, err := mime.ParseMediaType(contentType)
 	if err != nil {
-		return nil, err
+		return false
 	}
 
-	response := intensityResponse{}
-	if err := json.Unmarshal(responseBytes, &response); err != nil {
-		return nil, err
-	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
 
-	return response.entries, nil
+// GetIntensityForDay returns an array of Intensity objects, for all 30 minute settlement periods in day represented by date
+func (ah *APIHandler) GetIntensityForDay(date time.Time) ([]*Intensity, error) {
+	return ah.GetIntensityForDayContext(context.Background(), date)
+}
+
+// GetIntensityForDayContext is the same as GetIntensityForDay, but the request is made with the context ctx
+func (ah *APIHandler) GetIntensityForDayContext(ctx context.Context, date time.Time) ([]*Intensity, error) {
+	year, month, day := date.Date()
+
+	return ah.getIntensityResponse(ctx, fmt.Sprintf("/intensity/date/%04d-%02d-%02d", year, month, day))
 }
 
 // GetIntensityForDayAndSettlementPeriod returns an Intensity object, for the given 30 minute settlement period (settlementPeriod) in the day represented by date
 //
-// National grid split the day into 48 half-hour settlement periods.
+// National grid split the day into 48 half-hour settlement periods, or 46 or 50 on the days the clocks change.
 // The periods of the day follow UK local time.
-// The settlement periods are 1-index (numbered 1 to 48 inclusive).
+// The settlement periods are 1-index (numbered 1 to 48 inclusive on most days); see SettlementPeriod.
 func (ah *APIHandler) GetIntensityForDayAndSettlementPeriod(date time.Time, settlementPeriod int) (*Intensity, error) {
-	if settlementPeriod < 1 || settlementPeriod > 48 {
-		return nil, fmt.Errorf("Invalid settlmentPeriod %d; must be 1 <= settlementPeriod <= 48", settlementPeriod)
-	}
-
-	year, month, day := date.Date()
+	return ah.GetIntensityForDayAndSettlementPeriodContext(context.Background(), date, settlementPeriod)
+}
 
-	responseBytes, err := ah.getAPIResponse(fmt.Sprintf("/intensity/date/%04d-%02d-%02d/%d", year, month, day, settlementPeriod))
+// GetIntensityForDayAndSettlementPeriodContext is the same as GetIntensityForDayAndSettlementPeriod, but the request is made with the context ctx
+func (ah *APIHandler) GetIntensityForDayAndSettlementPeriodContext(ctx context.Context, date time.Time, settlementPeriod int) (*Intensity, error) {
+	sp, err := NewSettlementPeriod(date, settlementPeriod)
 	if err != nil {
 		return nil, err
 	}
 
-	response := intensityResponse{}
-	if err := json.Unmarshal(responseBytes, &response); err != nil {
-		return nil, err
-	}
+	return ah.GetIntensityForSettlementPeriodContext(ctx, sp)
+}
 
-	if len(response.entries) != 1 {
-		return nil, fmt.Errorf("Unexpected API response; unexpected number of entries; %s", string(responseBytes))
+// GetIntensityForSettlementPeriod returns an Intensity object, for the settlement period sp
+func (ah *APIHandler) GetIntensityForSettlementPeriod(sp SettlementPeriod) (*Intensity, error) {
+	return ah.GetIntensityForSettlementPeriodContext(context.Background(), sp)
+}
+
+// GetIntensityForSettlementPeriodContext is the same as GetIntensityForSettlementPeriod, but the request is made with the context ctx
+func (ah *APIHandler) GetIntensityForSettlementPeriodContext(ctx context.Context, sp SettlementPeriod) (*Intensity, error) {
+	if err := sp.Validate(); err != nil {
+		return nil, err
 	}
 
-	return response.entries[0], nil
+	return ah.getSingleIntensityResponse(ctx, fmt.Sprintf("/intensity/date/%s", sp))
 }
 
 // GetTodaysIntensity returns an array of Intensity objects, for all 30 minute settlement periods in the current day
@@ -213,36 +385,22 @@ func (ah *APIHandler) GetIntensityForDayAndSettlementPeriod(date time.Time, sett
 // I strongly considered implementing this as GetIntensityForDay(time.Now()) but I will use the dedicated /intensity/date resource
 // provided by the API. I would be very interested if the behaviour of these would ever differ (presumably round trip delay could cause this).
 func (ah *APIHandler) GetTodaysIntensity() ([]*Intensity, error) {
-	responseBytes, err := ah.getAPIResponse("/intensity/date")
-	if err != nil {
-		return nil, err
-	}
-
-	response := intensityResponse{}
-	if err := json.Unmarshal(responseBytes, &response); err != nil {
-		return nil, err
-	}
+	return ah.GetTodaysIntensityContext(context.Background())
+}
 
-	return response.entries, nil
+// GetTodaysIntensityContext is the same as GetTodaysIntensity, but the request is made with the context ctx
+func (ah *APIHandler) GetTodaysIntensityContext(ctx context.Context) ([]*Intensity, error) {
+	return ah.getIntensityResponse(ctx, "/intensity/date")
 }
 
 // GetIntensityForTimePeriod returns an Intensity object, for the 30 minute settlement period containing time
 func (ah *APIHandler) GetIntensityForTimePeriod(time time.Time) (*Intensity, error) {
-	responseBytes, err := ah.getAPIResponse(fmt.Sprintf("/intensity/%s", time.Format(natGridTimeFormat)))
-	if err != nil {
-		return nil, err
-	}
-
-	response := intensityResponse{}
-	if err := json.Unmarshal(responseBytes, &response); err != nil {
-		return nil, err
-	}
-
-	if len(response.entries) != 1 {
-		return nil, fmt.Errorf("Unexpected API response; unexpected number of entries; %s", string(responseBytes))
-	}
+	return ah.GetIntensityForTimePeriodContext(context.Background(), time)
+}
 
-	return response.entries[0], nil
+// GetIntensityForTimePeriodContext is the same as GetIntensityForTimePeriod, but the request is made with the context ctx
+func (ah *APIHandler) GetIntensityForTimePeriodContext(ctx context.Context, time time.Time) (*Intensity, error) {
+	return ah.getSingleIntensityResponse(ctx, fmt.Sprintf("/intensity/%s", time.Format(natGridTimeFormat)))
 }
 
 // GetCurrentIntensity returns an Intensity object, for the current 30 minute settlement period
@@ -250,191 +408,146 @@ func (ah *APIHandler) GetIntensityForTimePeriod(time time.Time) (*Intensity, err
 // I strongly considered implementing this as GetIntensityForTimePeriod(time.Now()) but I will use the dedicated /intensity resource
 // provided by the API. I would be very interested if the behaviour of these would ever differ (presumably round trip delay could cause this)
 func (ah *APIHandler) GetCurrentIntensity() (*Intensity, error) {
-	responseBytes, err := ah.getAPIResponse("/intensity")
-	if err != nil {
-		return nil, err
-	}
-
-	response := intensityResponse{}
-	if err := json.Unmarshal(responseBytes, &response); err != nil {
-		return nil, err
-	}
-
-	if len(response.entries) != 1 {
-		return nil, fmt.Errorf("Unexpected API response; unexpected number of entries; %s", string(responseBytes))
-	}
+	return ah.GetCurrentIntensityContext(context.Background())
+}
 
-	return response.entries[0], nil
+// GetCurrentIntensityContext is the same as GetCurrentIntensity, but the request is made with the context ctx
+func (ah *APIHandler) GetCurrentIntensityContext(ctx context.Context) (*Intensity, error) {
+	return ah.getSingleIntensityResponse(ctx, "/intensity")
 }
 
 // GetIntensityBetween returns an array of Intensity objects, for all 30 minute settlement periods between from and to
 //
 // The maximum date range is limited to 30 days
 func (ah *APIHandler) GetIntensityBetween(from time.Time, to time.Time) ([]*Intensity, error) {
-	if !from.Before(to) {
-		return nil, fmt.Errorf("from (%s) must be strictly earlier than to (%s)", from.String(), to.String())
-	}
-
-	if to.Sub(from) > (time.Hour * 24 * 30) {
-		return nil, fmt.Errorf("The maximum date range is limited to 30 days. From (%s) To (%s)", from.String(), to.String())
-	}
+	return ah.GetIntensityBetweenContext(context.Background(), from, to)
+}
 
-	responseBytes, err := ah.getAPIResponse(fmt.Sprintf("/intensity/%s/%s", from.Format(natGridTimeFormat), to.Format(natGridTimeFormat)))
-	if err != nil {
-		return nil, err
+// GetIntensityBetweenContext is the same as GetIntensityBetween, but the request is made with the context ctx
+func (ah *APIHandler) GetIntensityBetweenContext(ctx context.Context, from time.Time, to time.Time) ([]*Intensity, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w; from (%s) must be strictly earlier than to (%s)", ErrInvalidRange, from.String(), to.String())
 	}
 
-	response := intensityResponse{}
-	if err := json.Unmarshal(responseBytes, &response); err != nil {
-		return nil, err
+	if to.Sub(from) > maxDateRange {
+		return nil, fmt.Errorf("%w; the maximum date range is limited to 30 days. From (%s) To (%s)", ErrRangeTooLarge, from.String(), to.String())
 	}
 
-	return response.entries, nil
+	return ah.getIntensityResponse(ctx, fmt.Sprintf("/intensity/%s/%s", from.Format(natGridTimeFormat), to.Format(natGridTimeFormat)))
 }
 
 // GetNext24HourIntensity returns an array of Intensity objects, for all 30 minute settlement periods between from and from+24h
 //
 // While this could be implemented using GetIntensityBetween it uses the dedicated /intensity/{from}/fw24h resource
 func (ah *APIHandler) GetNext24HourIntensity(from time.Time) ([]*Intensity, error) {
-	responseBytes, err := ah.getAPIResponse(fmt.Sprintf("/intensity/%s/fw24h", from.Format(natGridTimeFormat)))
-	if err != nil {
-		return nil, err
-	}
-
-	response := intensityResponse{}
-	if err := json.Unmarshal(responseBytes, &response); err != nil {
-		return nil, err
-	}
+	return ah.GetNext24HourIntensityContext(context.Background(), from)
+}
 
-	return response.entries, nil
+// GetNext24HourIntensityContext is the same as GetNext24HourIntensity, but the request is made with the context ctx
+func (ah *APIHandler) GetNext24HourIntensityContext(ctx context.Context, from time.Time) ([]*Intensity, error) {
+	return ah.getIntensityResponse(ctx, fmt.Sprintf("/intensity/%s/fw24h", from.Format(natGridTimeFormat)))
 }
 
 // GetNext48HourIntensity returns an array of Intensity objects, for all 30 minute settlement periods between from and from+48h
 //
 // While this could be implemented using GetIntensityBetween it uses the dedicated /intensity/{from}/fw48h resource
 func (ah *APIHandler) GetNext48HourIntensity(from time.Time) ([]*Intensity, error) {
-	responseBytes, err := ah.getAPIResponse(fmt.Sprintf("/intensity/%s/fw48h", from.Format(natGridTimeFormat)))
-	if err != nil {
-		return nil, err
-	}
-
-	response := intensityResponse{}
-	if err := json.Unmarshal(responseBytes, &response); err != nil {
-		return nil, err
-	}
+	return ah.GetNext48HourIntensityContext(context.Background(), from)
+}
 
-	return response.entries, nil
+// GetNext48HourIntensityContext is the same as GetNext48HourIntensity, but the request is made with the context ctx
+func (ah *APIHandler) GetNext48HourIntensityContext(ctx context.Context, from time.Time) ([]*Intensity, error) {
+	return ah.getIntensityResponse(ctx, fmt.Sprintf("/intensity/%s/fw48h", from.Format(natGridTimeFormat)))
 }
 
 // GetPrior24HourIntensity returns an array of Intensity objects, for all 30 minute settlement periods between from-24h and from
 //
 // While this could be implemented using GetIntensityBetween it uses the dedicated /intensity/{from}/pt24h resource
 func (ah *APIHandler) GetPrior24HourIntensity(from time.Time) ([]*Intensity, error) {
-	responseBytes, err := ah.getAPIResponse(fmt.Sprintf("/intensity/%s/pt24h", from.Format(natGridTimeFormat)))
-	if err != nil {
-		return nil, err
-	}
-
-	response := intensityResponse{}
-	if err := json.Unmarshal(responseBytes, &response); err != nil {
-		return nil, err
-	}
+	return ah.GetPrior24HourIntensityContext(context.Background(), from)
+}
 
-	return response.entries, nil
+// GetPrior24HourIntensityContext is the same as GetPrior24HourIntensity, but the request is made with the context ctx
+func (ah *APIHandler) GetPrior24HourIntensityContext(ctx context.Context, from time.Time) ([]*Intensity, error) {
+	return ah.getIntensityResponse(ctx, fmt.Sprintf("/intensity/%s/pt24h", from.Format(natGridTimeFormat)))
 }
 
 // GetIntensityFactors gets an IntensityFactors struct
 func (ah *APIHandler) GetIntensityFactors() (*IntensityFactors, error) {
-	responseBytes, err := ah.getAPIResponse("/intensity/factors")
+	return ah.GetIntensityFactorsContext(context.Background())
+}
+
+// GetIntensityFactorsContext is the same as GetIntensityFactors, but the request is made with the context ctx
+func (ah *APIHandler) GetIntensityFactorsContext(ctx context.Context) (*IntensityFactors, error) {
+	responseBytes, err := ah.getAPIResponse(ctx, "/intensity/factors")
 	if err != nil {
 		return nil, err
 	}
 
-	var response map[string]interface{}
-	if err := json.Unmarshal(responseBytes, &response); err != nil {
+	var factors []*IntensityFactors
+	if err := decodeAPIDataList(responseBytes, &factors); err != nil {
 		return nil, err
 	}
 
-	if response["data"] == nil {
-		if response["error"] == nil {
-			return nil, fmt.Errorf("Failed to unmarshal JSON; %s", string(responseBytes))
-		}
-
-		errorMap := response["error"].(map[string]interface{})
-		return nil, fmt.Errorf("API error; Code: %s Message: %s", errorMap["code"].(string), errorMap["message"].(string))
+	if len(factors) != 1 {
+		return nil, fmt.Errorf("%w; unexpected number of entries; %s", ErrUnexpectedResponse, truncateBody(responseBytes))
 	}
 
-	responseData := response["data"].([]interface{})
-
-	if len(responseData) != 1 {
-		return nil, fmt.Errorf("Unexpected API response; unexpected number of entries; %s", string(responseBytes))
+	if factors[0] == nil {
+		return nil, &ResponseError{
+			StatusCode: http.StatusOK,
+			URL:        ah.serverAddress + "/intensity/factors",
+			Body:       truncateBody(responseBytes),
+			Reason:     "entry is null",
+		}
 	}
 
-	factorDict := responseData[0].(map[string]interface{})
-
-	return &IntensityFactors{
-		Biomass:          int(factorDict["Biomass"].(float64)),
-		Coal:             int(factorDict["Coal"].(float64)),
-		DutchImports:     int(factorDict["Dutch Imports"].(float64)),
-		FrenchImports:    int(factorDict["French Imports"].(float64)),
-		GasCombinedCycle: int(factorDict["Gas (Combined Cycle)"].(float64)),
-		GasOpenCycle:     int(factorDict["Gas (Open Cycle)"].(float64)),
-		Hydro:            int(factorDict["Hydro"].(float64)),
-		IrishImports:     int(factorDict["Irish Imports"].(float64)),
-		Nuclear:          int(factorDict["Nuclear"].(float64)),
-		Oil:              int(factorDict["Oil"].(float64)),
-		Other:            int(factorDict["Other"].(float64)),
-		PumpedStorage:    int(factorDict["Pumped Storage"].(float64)),
-		Solar:            int(factorDict["Solar"].(float64)),
-		Wind:             int(factorDict["Wind"].(float64)),
-	}, nil
+	return factors[0], nil
 }
 
 func (sr *statisticsResponse) UnmarshalJSON(data []byte) error {
-	var decoded map[string]interface{}
-	if err := json.Unmarshal(data, &decoded); err != nil {
-		return err
-	}
-
-	if decoded["data"] == nil {
-		if decoded["error"] == nil {
-			return fmt.Errorf("Failed to unmarshal JSON; %s", string(data))
+	return sr.decodeEntries(data, func(index int, rawEntry json.RawMessage) error {
+		var decodedEntry apiIntensityEntry
+		if err := json.Unmarshal(rawEntry, &decodedEntry); err != nil {
+			return err
 		}
 
-		errorMap := decoded["error"].(map[string]interface{})
-		return fmt.Errorf("API error; Code: %s Message: %s", errorMap["code"].(string), errorMap["message"].(string))
-	}
-
-	decodedData := decoded["data"].([]interface{})
-	sr.entries = make([]*Statistics, 0, len(decodedData))
-
-	for _, value := range decodedData {
-		decodedDataEntry := value.(map[string]interface{})
-
-		toTime, err := time.Parse(natGridTimeFormat, decodedDataEntry["to"].(string))
+		newEntry, err := newStatistics(&decodedEntry.apiPeriod, decodedEntry.Intensity)
 		if err != nil {
-			return nil
+			return err
 		}
 
-		fromTime, err := time.Parse(natGridTimeFormat, decodedDataEntry["from"].(string))
-		if err != nil {
-			return nil
-		}
+		sr.entries = append(sr.entries, newEntry)
+		return nil
+	})
+}
 
-		decodedIntensity := decodedDataEntry["intensity"].(map[string]interface{})
+// getStatisticsResponse returns the entries of the response from the API for resource
+func (ah *APIHandler) getStatisticsResponse(ctx context.Context, resource string) ([]*Statistics, error) {
+	responseBytes, err := ah.getAPIResponse(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
 
-		newEntry := &Statistics{To: toTime,
-			From:    fromTime,
-			Max:     unmarshalInt(decodedIntensity["max"], -1),
-			Average: unmarshalInt(decodedIntensity["average"], -1),
-			Min:     unmarshalInt(decodedIntensity["min"], -1),
-			Index:   decodedIntensity["index"].(string),
-		}
+	response := statisticsResponse{entryDecoder: entryDecoder{lenient: ah.lenientDecoding}}
+	if err := unmarshalAPIResponse(responseBytes, &response); err != nil {
+		return nil, err
+	}
 
-		sr.entries = append(sr.entries, newEntry)
+	return response.entries, response.err()
+}
+
+func (ah *APIHandler) getSingleStatisticsResponse(ctx context.Context, resource string) (*Statistics, error) {
+	entries, err := ah.getStatisticsResponse(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) != 1 {
+		return nil, fmt.Errorf("%w; unexpected number of entries (%d) for %s", ErrUnexpectedResponse, len(entries), resource)
 	}
 
-	return nil
+	return entries[0], nil
 }
 
 func (se *Statistics) String() string {
@@ -442,33 +555,39 @@ func (se *Statistics) String() string {
 		se.Max, se.Average, se.Min, se.Index)
 }
 
+// MaxValue returns Max, and whether it is known (i.e. isn't -1)
+func (se *Statistics) MaxValue() (int, bool) {
+	return se.Max, se.Max != -1
+}
+
+// AverageValue returns Average, and whether it is known (i.e. isn't -1)
+func (se *Statistics) AverageValue() (int, bool) {
+	return se.Average, se.Average != -1
+}
+
+// MinValue returns Min, and whether it is known (i.e. isn't -1)
+func (se *Statistics) MinValue() (int, bool) {
+	return se.Min, se.Min != -1
+}
+
 // GetStatistics returns a Statistics object giving carbon intensity statistics for the period between from and to
 //
 // The maximum date range is limited to 30 days
 func (ah *APIHandler) GetStatistics(from time.Time, to time.Time) (*Statistics, error) {
-	if !from.Before(to) {
-		return nil, fmt.Errorf("from (%s) must be strictly earlier than to (%s)", from.String(), to.String())
-	}
-
-	if to.Sub(from) > (time.Hour * 24 * 30) {
-		return nil, fmt.Errorf("The maximum date range is limited to 30 days. From (%s) To (%s)", from.String(), to.String())
-	}
-
-	responseBytes, err := ah.getAPIResponse(fmt.Sprintf("/intensity/stats/%s/%s", from.Format(natGridTimeFormat), to.Format(natGridTimeFormat)))
-	if err != nil {
-		return nil, err
-	}
+	return ah.GetStatisticsContext(context.Background(), from, to)
+}
 
-	response := statisticsResponse{}
-	if err := json.Unmarshal(responseBytes, &response); err != nil {
-		return nil, err
+// GetStatisticsContext is the same as GetStatistics, but the request is made with the context ctx
+func (ah *APIHandler) GetStatisticsContext(ctx context.Context, from time.Time, to time.Time) (*Statistics, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w; from (%s) must be strictly earlier than to (%s)", ErrInvalidRange, from.String(), to.String())
 	}
 
-	if len(response.entries) != 1 {
-		return nil, fmt.Errorf("Unexpected API response; unexpected number of entries; %s", string(responseBytes))
+	if to.Sub(from) > maxDateRange {
+		return nil, fmt.Errorf("%w; the maximum date range is limited to 30 days. From (%s) To (%s)", ErrRangeTooLarge, from.String(), to.String())
 	}
 
-	return response.entries[0], nil
+	return ah.getSingleStatisticsResponse(ctx, fmt.Sprintf("/intensity/stats/%s/%s", from.Format(natGridTimeFormat), to.Format(natGridTimeFormat)))
 }
 
 // GetStatisticsInBlocks returns an array of Statistics object giving carbon intensity statistics for the period between from and to
@@ -477,30 +596,25 @@ func (ah *APIHandler) GetStatistics(from time.Time, to time.Time) (*Statistics,
 // The maximum date range is limited to 30 days.
 // The block size given by blockSize is rounded down to the nearest hour and must be between 1 and 24 inclusive.
 func (ah *APIHandler) GetStatisticsInBlocks(from time.Time, to time.Time, blockSize time.Duration) ([]*Statistics, error) {
+	return ah.GetStatisticsInBlocksContext(context.Background(), from, to, blockSize)
+}
+
+// GetStatisticsInBlocksContext is the same as GetStatisticsInBlocks, but the request is made with the context ctx
+func (ah *APIHandler) GetStatisticsInBlocksContext(ctx context.Context, from time.Time, to time.Time, blockSize time.Duration) ([]*Statistics, error) {
 	if !from.Before(to) {
-		return nil, fmt.Errorf("from (%s) must be strictly earlier than to (%s)", from.String(), to.String())
+		return nil, fmt.Errorf("%w; from (%s) must be strictly earlier than to (%s)", ErrInvalidRange, from.String(), to.String())
 	}
 
-	if to.Sub(from) > (time.Hour * 24 * 30) {
-		return nil, fmt.Errorf("The maximum date range is limited to 30 days. From (%s) To (%s)", from.String(), to.String())
+	if to.Sub(from) > maxDateRange {
+		return nil, fmt.Errorf("%w; the maximum date range is limited to 30 days. From (%s) To (%s)", ErrRangeTooLarge, from.String(), to.String())
 	}
 
 	blockSizeHours := int(blockSize.Hours())
 
 	if blockSizeHours < 1 || blockSizeHours > 24 {
-		return nil, fmt.Errorf("Invalid blocksize %s; must be between 1 and 24 hours inclusive", blockSize.String())
+		return nil, fmt.Errorf("%w %s; must be between 1 and 24 hours inclusive", ErrInvalidBlockSize, blockSize.String())
 	}
 
-	responseBytes, err := ah.getAPIResponse(fmt.Sprintf("/intensity/stats/%s/%s/%d", from.Format(natGridTimeFormat),
+	return ah.getStatisticsResponse(ctx, fmt.Sprintf("/intensity/stats/%s/%s/%d", from.Format(natGridTimeFormat),
 		to.Format(natGridTimeFormat), blockSizeHours))
-	if err != nil {
-		return nil, err
-	}
-
-	response := statisticsResponse{}
-	if err := json.Unmarshal(responseBytes, &response); err != nil {
-		return nil, err
-	}
-
-	return response.entries, nil
 }
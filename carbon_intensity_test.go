@@ -1,6 +1,13 @@
 package carbonintensity
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,8 +17,310 @@ import (
 // TODO: For now tests just function to make sure we don't crash against the real server
 // Other tests should be written to point at a test server that will return known data that we can check we are correctly parsing
 
+// newTestAPIHandler returns an APIHandler pointed at a test server which serves the canned response bodies in responses,
+// keyed by request path. Any other path gets a 404.
+func newTestAPIHandler(t *testing.T, responses map[string]string) (*APIHandler, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			t.Logf("Unexpected request for %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+
+	return NewCarbonIntensityAPIHandler(WithBaseURL(server.URL)), server.Close
+}
+
+// testPeriodServer is a test server which generates data for every settlement period in any range requested of
+// /intensity/{from}/{to}, /intensity/{from}/fw24h, /intensity/{from}/fw48h and /generation/{from}/{to}, and statistics for
+// /intensity/stats/{from}/{to} and /intensity/stats/{from}/{to}/{block}. As the real API does, ranges starting at the start of a
+// period include the period ending there, and ranges over 30 days are rejected.
+//
+// Its fields are set before it is started with startTestPeriodServer, and changed afterwards with update.
+type testPeriodServer struct {
+	*httptest.Server
+
+	// forecast returns the forecast intensity of the period starting at from, 200 if it is nil
+	forecast func(from time.Time) int
+	// actual returns the actual intensity of the period starting at from and whether there is one, there never is if it is nil
+	actual func(from time.Time) (int, bool)
+	// Periods starting at missing are left out
+	missing time.Time
+	// The next failures requests fail with failureStatus (503 if it is zero), and a Retry-After of retryAfter if it isn't empty.
+	// Every request fails if failures is negative.
+	failures      int
+	failureStatus int
+	retryAfter    string
+	// Each path requested is sent to notify, if it isn't nil, once it has been recorded
+	notify chan<- string
+
+	mutex     sync.Mutex
+	requested *sync.Cond
+	requests  []string
+}
+
+// startTestPeriodServer starts s, returning it
+func startTestPeriodServer(s *testPeriodServer) *testPeriodServer {
+	s.requested = sync.NewCond(&s.mutex)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.notify != nil {
+			defer func() { s.notify <- r.URL.Path }()
+		}
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		s.requests = append(s.requests, r.URL.Path)
+		s.requested.Broadcast()
+
+		if s.failures != 0 {
+			if s.failures > 0 {
+				s.failures--
+			}
+
+			if s.retryAfter != "" {
+				w.Header().Set("Retry-After", s.retryAfter)
+			}
+
+			if s.failureStatus == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			} else {
+				w.WriteHeader(s.failureStatus)
+			}
+			return
+		}
+
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if len(parts) < 3 || (parts[0] != "intensity" && parts[0] != "generation") {
+			http.NotFound(w, r)
+			return
+		}
+
+		statistics := parts[1] == "stats"
+		if statistics {
+			parts = parts[1:]
+		}
+
+		from, err := time.Parse(natGridTimeFormat, parts[1])
+		to, toErr := time.Parse(natGridTimeFormat, parts[2])
+		switch {
+		case parts[2] == "fw24h":
+			to, toErr = from.Add(24*time.Hour), nil
+		case parts[2] == "fw48h":
+			to, toErr = from.Add(48*time.Hour), nil
+		}
+
+		if err != nil || toErr != nil || !from.Before(to) || to.Sub(from) > maxDateRange {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":"400 Bad Request","message":"Please enter a valid date range"}}`))
+			return
+		}
+
+		var entries []string
+		switch {
+		case statistics && len(parts) == 4:
+			var blockHours int
+			fmt.Sscanf(parts[3], "%d", &blockHours)
+			block := time.Duration(blockHours) * time.Hour
+
+			for blockFrom := from; blockFrom.Before(to); blockFrom = blockFrom.Add(block) {
+				entries = append(entries, fmt.Sprintf(`{"from":"%s","to":"%s","intensity":{"max":300,"average":200,"min":100,"index":"moderate"}}`,
+					blockFrom.Format(natGridTimeFormat), blockFrom.Add(block).Format(natGridTimeFormat)))
+			}
+		case statistics:
+			// Each range starting on a different day gets different statistics, so that combining them can be checked
+			average := 100 + from.YearDay()
+			index := IndexLow
+			if from.YearDay() > 1 {
+				index = IndexHigh
+			}
+
+			entries = append(entries, fmt.Sprintf(`{"from":"%s","to":"%s","intensity":{"max":%d,"average":%d,"min":%d,"index":"%s"}}`,
+				parts[1], parts[2], average+from.YearDay(), average, average-from.YearDay(), index))
+		default:
+			periodFrom := from.Truncate(settlementPeriodDuration)
+			if periodFrom.Equal(from) {
+				periodFrom = periodFrom.Add(-settlementPeriodDuration)
+			}
+
+			for ; periodFrom.Before(to); periodFrom = periodFrom.Add(settlementPeriodDuration) {
+				if periodFrom.Equal(s.missing) {
+					continue
+				}
+
+				periodTo := periodFrom.Add(settlementPeriodDuration)
+				if parts[0] == "generation" {
+					entries = append(entries, fmt.Sprintf(`{"from":"%s","to":"%s","generationmix":[{"fuel":"wind","perc":%d}]}`,
+						periodFrom.Format(natGridTimeFormat), periodTo.Format(natGridTimeFormat), periodFrom.Hour()))
+					continue
+				}
+
+				forecast := 200
+				if s.forecast != nil {
+					forecast = s.forecast(periodFrom)
+				}
+
+				actual := "null"
+				if s.actual != nil {
+					if value, ok := s.actual(periodFrom); ok {
+						actual = fmt.Sprint(value)
+					}
+				}
+
+				entries = append(entries, fmt.Sprintf(`{"from":"%s","to":"%s","intensity":{"forecast":%d,"actual":%s,"index":"moderate"}}`,
+					periodFrom.Format(natGridTimeFormat), periodTo.Format(natGridTimeFormat), forecast, actual))
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[` + strings.Join(entries, ",") + `]}`))
+	}))
+
+	return s
+}
+
+// update calls update with the server locked, so that it can change the server's fields while it is running
+func (s *testPeriodServer) update(update func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	update()
+}
+
+// paths returns the paths requested so far
+func (s *testPeriodServer) paths() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+// requestCount returns how many requests have been made so far
+func (s *testPeriodServer) requestCount() int {
+	return len(s.paths())
+}
+
+// waitForRequests waits until the server has received count requests in total
+func (s *testPeriodServer) waitForRequests(count int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for len(s.requests) < count {
+		s.requested.Wait()
+	}
+}
+
+// liveTestTime is the time the tests which use the real API ask about. It is fixed, rather than the current time, so that the
+// requests are the same on every run and their recorded fixtures can be replayed.
+var liveTestTime = time.Date(2018, 5, 15, 12, 0, 0, 0, time.UTC)
+
+// liveFixturesDir is where the responses of the real API to the tests which use it are recorded
+const liveFixturesDir = "testdata/fixtures"
+
+// newLiveAPIHandler returns an APIHandler for the tests which use the real API.
+//
+// By default responses are replayed from the fixtures in liveFixturesDir, and the test is skipped if none have been recorded, so
+// the tests don't need the network. Setting CARBONINTENSITY_FIXTURES to "record" records them from the real API, and "live" uses it
+// without fixtures.
+func newLiveAPIHandler(t *testing.T) *APIHandler {
+	mode := FixturesReplay
+	if name := os.Getenv(FixtureModeEnvVar); name != "" {
+		var err error
+		if mode, err = ParseFixtureMode(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if mode == FixturesReplay || mode == FixturesReplayStrict {
+		if _, err := os.Stat(liveFixturesDir); os.IsNotExist(err) {
+			t.Skipf("No responses of the real API are recorded in %s; run the tests with %s=record to record them", liveFixturesDir,
+				FixtureModeEnvVar)
+		}
+	}
+
+	return NewCarbonIntensityAPIHandler(WithFixtures(liveFixturesDir, mode))
+}
+
+func TestContextCancellation(t *testing.T) {
+	requestReceived := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requestReceived)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-requestReceived
+		cancel()
+	}()
+
+	intensity, err := handler.GetCurrentIntensityContext(ctx)
+	assert.Error(t, err)
+	assert.Nil(t, intensity)
+	assert.Equal(t, context.Canceled, ctx.Err())
+
+	// An already expired deadline should fail without waiting on the server
+	ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
+	intensityArr, err := handler.GetIntensityBetweenContext(ctx, time.Now().Add(-time.Hour), time.Now())
+	assert.Error(t, err)
+	assert.Nil(t, intensityArr)
+}
+
+func TestIntensityOptionalValues(t *testing.T) {
+	past := &Intensity{Forecast: 266, Actual: 263}
+	future := &Intensity{Forecast: 250, Actual: -1}
+	missing := &Intensity{Forecast: -1, Actual: -1}
+
+	assert.True(t, past.HasActual())
+	assert.False(t, future.HasActual())
+
+	value, ok := past.ActualValue()
+	assert.True(t, ok)
+	assert.Equal(t, 263, value)
+
+	_, ok = future.ActualValue()
+	assert.False(t, ok)
+
+	value, ok = past.Best()
+	assert.True(t, ok)
+	assert.Equal(t, 263, value)
+
+	value, ok = future.Best()
+	assert.True(t, ok)
+	assert.Equal(t, 250, value)
+
+	_, ok = missing.Best()
+	assert.False(t, ok)
+
+	_, ok = missing.ForecastValue()
+	assert.False(t, ok)
+
+	stats := &Statistics{Max: 300, Average: -1, Min: 100}
+
+	value, ok = stats.MaxValue()
+	assert.True(t, ok)
+	assert.Equal(t, 300, value)
+
+	_, ok = stats.AverageValue()
+	assert.False(t, ok)
+
+	value, ok = stats.MinValue()
+	assert.True(t, ok)
+	assert.Equal(t, 100, value)
+}
+
 func TestCurrentIntensity(t *testing.T) {
-	handler := NewCarbonIntensityAPIHandler()
+	handler := newLiveAPIHandler(t)
 
 	intensity, err := handler.GetCurrentIntensity()
 	assert.NoError(t, err)
@@ -19,60 +328,68 @@ func TestCurrentIntensity(t *testing.T) {
 }
 
 func TestOtherTimePeriodsIntensity(t *testing.T) {
-	handler := NewCarbonIntensityAPIHandler()
+	handler := newLiveAPIHandler(t)
 
-	intensity, err := handler.GetIntensityForTimePeriod(time.Now().Add(8 * time.Hour))
+	intensity, err := handler.GetIntensityForTimePeriod(liveTestTime.Add(8 * time.Hour))
 	assert.NoError(t, err)
 	t.Logf("%v\n", intensity)
 
-	intensity, err = handler.GetIntensityForTimePeriod(time.Now().Add(-8 * time.Hour))
+	intensity, err = handler.GetIntensityForTimePeriod(liveTestTime.Add(-8 * time.Hour))
 	assert.NoError(t, err)
 	t.Logf("%v\n", intensity)
 }
 
 func TestOtherDayAndSettlementPeriodIntensity(t *testing.T) {
-	handler := NewCarbonIntensityAPIHandler()
+	handler := newLiveAPIHandler(t)
 
 	// The day is split into 48 half-hour settlement periods
 	// Joyfully (and perhaps obviously) the periods of the day follow local time (and so British Summer Time)
 	// They are also 1-index (numbered 1 to 48 inclusive)
-	// Fortunately time.In() is a pretty sweet function!
+	// Fortunately time.In() is a pretty sweet function! liveTestTime is during British Summer Time, so this is checked.
 	london, err := time.LoadLocation("Europe/London")
-	assert.NoError(t, err)
+	if !assert.NoError(t, err) {
+		return
+	}
 
-	intensity, err := handler.GetIntensityForDayAndSettlementPeriod(time.Now().Add(-24*time.Hour), 1)
-	assert.NoError(t, err)
+	intensity, err := handler.GetIntensityForDayAndSettlementPeriod(liveTestTime.Add(-24*time.Hour), 1)
+	if !assert.NoError(t, err) {
+		return
+	}
 	assert.Equal(t, 0, intensity.From.In(london).Hour())
 	assert.Equal(t, 0, intensity.From.In(london).Minute())
 	assert.Equal(t, 0, intensity.To.In(london).Hour())
 	assert.Equal(t, 30, intensity.To.In(london).Minute())
 	t.Logf("%v\n", intensity)
 
-	intensity, err = handler.GetIntensityForDayAndSettlementPeriod(time.Now().Add(-24*time.Hour), 24)
+	intensity, err = handler.GetIntensityForDayAndSettlementPeriod(liveTestTime.Add(-24*time.Hour), 24)
+	if !assert.NoError(t, err) {
+		return
+	}
 	assert.Equal(t, 11, intensity.From.In(london).Hour())
 	assert.Equal(t, 30, intensity.From.In(london).Minute())
 	assert.Equal(t, 12, intensity.To.In(london).Hour())
 	assert.Equal(t, 00, intensity.To.In(london).Minute())
-	assert.NoError(t, err)
 	t.Logf("%v\n", intensity)
 
-	intensity, err = handler.GetIntensityForDayAndSettlementPeriod(time.Now().Add(-24*time.Hour), 48)
+	intensity, err = handler.GetIntensityForDayAndSettlementPeriod(liveTestTime.Add(-24*time.Hour), 48)
+	if !assert.NoError(t, err) {
+		return
+	}
 	assert.Equal(t, 23, intensity.From.In(london).Hour())
 	assert.Equal(t, 30, intensity.From.In(london).Minute())
 	assert.Equal(t, 0, intensity.To.In(london).Hour())
 	assert.Equal(t, 0, intensity.To.In(london).Minute())
-	assert.NoError(t, err)
 	t.Logf("%v\n", intensity)
 
-	intensity, err = handler.GetIntensityForDayAndSettlementPeriod(time.Now().Add(-24*time.Hour), 0)
+	intensity, err = handler.GetIntensityForDayAndSettlementPeriod(liveTestTime.Add(-24*time.Hour), 0)
 	assert.Error(t, err)
 
-	intensity, err = handler.GetIntensityForDayAndSettlementPeriod(time.Now().Add(-24*time.Hour), 49)
+	intensity, err = handler.GetIntensityForDayAndSettlementPeriod(liveTestTime.Add(-24*time.Hour), 49)
 	assert.Error(t, err)
 }
 
 func TestTodaysIntensity(t *testing.T) {
-	handler := NewCarbonIntensityAPIHandler()
+	handler := newLiveAPIHandler(t)
 
 	intensityArr, err := handler.GetTodaysIntensity()
 	assert.NoError(t, err)
@@ -82,15 +399,15 @@ func TestTodaysIntensity(t *testing.T) {
 }
 
 func TestOtherDaysIntensity(t *testing.T) {
-	handler := NewCarbonIntensityAPIHandler()
+	handler := newLiveAPIHandler(t)
 
-	intensityArr, err := handler.GetIntensityForDay(time.Now().Add(24 * time.Hour))
+	intensityArr, err := handler.GetIntensityForDay(liveTestTime.Add(24 * time.Hour))
 	assert.NoError(t, err)
 	for _, intensity := range intensityArr {
 		t.Logf("%v\n", intensity)
 	}
 
-	intensityArr, err = handler.GetIntensityForDay(time.Now().Add(-24 * time.Hour))
+	intensityArr, err = handler.GetIntensityForDay(liveTestTime.Add(-24 * time.Hour))
 	assert.NoError(t, err)
 	for _, intensity := range intensityArr {
 		t.Logf("%v\n", intensity)
@@ -98,31 +415,31 @@ func TestOtherDaysIntensity(t *testing.T) {
 }
 
 func TestIntensityBetween(t *testing.T) {
-	handler := NewCarbonIntensityAPIHandler()
+	handler := newLiveAPIHandler(t)
 
-	intensityArr, err := handler.GetIntensityBetween(time.Now().Add(-24*time.Hour), time.Now().Add(24*time.Hour))
+	intensityArr, err := handler.GetIntensityBetween(liveTestTime.Add(-24*time.Hour), liveTestTime.Add(24*time.Hour))
 	assert.NoError(t, err)
 	for _, intensity := range intensityArr {
 		t.Logf("%v\n", intensity)
 	}
 
 	// to and from equal, should return error
-	intensityArr, err = handler.GetIntensityBetween(time.Now(), time.Now())
+	intensityArr, err = handler.GetIntensityBetween(liveTestTime, liveTestTime)
 	assert.Error(t, err)
 
 	// to before from, should return error
-	intensityArr, err = handler.GetIntensityBetween(time.Now().Add(48*time.Hour), time.Now().Add(24*time.Hour))
+	intensityArr, err = handler.GetIntensityBetween(liveTestTime.Add(48*time.Hour), liveTestTime.Add(24*time.Hour))
 	assert.Error(t, err)
 
 	// > 30 day period, should return error
-	intensityArr, err = handler.GetIntensityBetween(time.Now().Add(-24*31*time.Hour), time.Now())
+	intensityArr, err = handler.GetIntensityBetween(liveTestTime.Add(-24*31*time.Hour), liveTestTime)
 	assert.Error(t, err)
 }
 
 func TestNext24HourIntensity(t *testing.T) {
-	handler := NewCarbonIntensityAPIHandler()
+	handler := newLiveAPIHandler(t)
 
-	intensityArr, err := handler.GetNext24HourIntensity(time.Now())
+	intensityArr, err := handler.GetNext24HourIntensity(liveTestTime)
 	assert.NoError(t, err)
 	t.Log(len(intensityArr))
 	for _, intensity := range intensityArr {
@@ -131,9 +448,9 @@ func TestNext24HourIntensity(t *testing.T) {
 }
 
 func TestNext48HourIntensity(t *testing.T) {
-	handler := NewCarbonIntensityAPIHandler()
+	handler := newLiveAPIHandler(t)
 
-	intensityArr, err := handler.GetNext48HourIntensity(time.Now())
+	intensityArr, err := handler.GetNext48HourIntensity(liveTestTime)
 	assert.NoError(t, err)
 	t.Log(len(intensityArr))
 	for _, intensity := range intensityArr {
@@ -142,9 +459,9 @@ func TestNext48HourIntensity(t *testing.T) {
 }
 
 func TestPrior24HourIntensity(t *testing.T) {
-	handler := NewCarbonIntensityAPIHandler()
+	handler := newLiveAPIHandler(t)
 
-	intensityArr, err := handler.GetPrior24HourIntensity(time.Now())
+	intensityArr, err := handler.GetPrior24HourIntensity(liveTestTime)
 	assert.NoError(t, err)
 	for _, intensity := range intensityArr {
 		t.Logf("%v\n", intensity)
@@ -152,7 +469,7 @@ func TestPrior24HourIntensity(t *testing.T) {
 }
 
 func TestIntensityFactors(t *testing.T) {
-	handler := NewCarbonIntensityAPIHandler()
+	handler := newLiveAPIHandler(t)
 
 	factors, err := handler.GetIntensityFactors()
 	assert.NoError(t, err)
@@ -160,31 +477,31 @@ func TestIntensityFactors(t *testing.T) {
 }
 
 func TestStatistics(t *testing.T) {
-	handler := NewCarbonIntensityAPIHandler()
+	handler := newLiveAPIHandler(t)
 
 	// Let's try a week of stats
-	stats, err := handler.GetStatistics(time.Now().Add(time.Hour*(24*-7)), time.Now())
+	stats, err := handler.GetStatistics(liveTestTime.Add(time.Hour*(24*-7)), liveTestTime)
 	assert.NoError(t, err)
 	t.Logf("%v\n", stats)
 
 	// to and from equal, should return error
-	stats, err = handler.GetStatistics(time.Now(), time.Now())
+	stats, err = handler.GetStatistics(liveTestTime, liveTestTime)
 	assert.Error(t, err)
 
 	// to before from, should return error
-	stats, err = handler.GetStatistics(time.Now().Add(48*time.Hour), time.Now().Add(24*time.Hour))
+	stats, err = handler.GetStatistics(liveTestTime.Add(48*time.Hour), liveTestTime.Add(24*time.Hour))
 	assert.Error(t, err)
 
 	// > 30 day period, should return error
-	stats, err = handler.GetStatistics(time.Now().Add(-24*31*time.Hour), time.Now())
+	stats, err = handler.GetStatistics(liveTestTime.Add(-24*31*time.Hour), liveTestTime)
 	assert.Error(t, err)
 }
 
 func TestStatisticsInBlocks(t *testing.T) {
-	handler := NewCarbonIntensityAPIHandler()
+	handler := newLiveAPIHandler(t)
 
 	// Let's try a week of stats, in 4 hour blocks
-	statsArr, err := handler.GetStatisticsInBlocks(time.Now().Add(time.Hour*(24*-7)), time.Now(), time.Hour*4)
+	statsArr, err := handler.GetStatisticsInBlocks(liveTestTime.Add(time.Hour*(24*-7)), liveTestTime, time.Hour*4)
 	assert.NoError(t, err)
 	assert.Equal(t, 42, len(statsArr))
 	for _, stats := range statsArr {
@@ -192,22 +509,22 @@ func TestStatisticsInBlocks(t *testing.T) {
 	}
 
 	// to and from equal, should return error
-	statsArr, err = handler.GetStatisticsInBlocks(time.Now(), time.Now(), time.Hour*4)
+	statsArr, err = handler.GetStatisticsInBlocks(liveTestTime, liveTestTime, time.Hour*4)
 	assert.Error(t, err)
 
 	// to before from, should return error
-	statsArr, err = handler.GetStatisticsInBlocks(time.Now().Add(48*time.Hour), time.Now().Add(24*time.Hour), time.Hour*4)
+	statsArr, err = handler.GetStatisticsInBlocks(liveTestTime.Add(48*time.Hour), liveTestTime.Add(24*time.Hour), time.Hour*4)
 	assert.Error(t, err)
 
 	// > 30 day period, should return error
-	statsArr, err = handler.GetStatisticsInBlocks(time.Now().Add(-24*31*time.Hour), time.Now(), time.Hour*4)
+	statsArr, err = handler.GetStatisticsInBlocks(liveTestTime.Add(-24*31*time.Hour), liveTestTime, time.Hour*4)
 	assert.Error(t, err)
 
 	// 0 hour blockSize, should return error
-	statsArr, err = handler.GetStatisticsInBlocks(time.Now().Add(time.Hour*(24*-7)), time.Now(), time.Minute*59)
+	statsArr, err = handler.GetStatisticsInBlocks(liveTestTime.Add(time.Hour*(24*-7)), liveTestTime, time.Minute*59)
 	assert.Error(t, err)
 
 	// 24 hour blockSize, should return error
-	statsArr, err = handler.GetStatisticsInBlocks(time.Now().Add(time.Hour*(24*-7)), time.Now(), time.Hour*25)
+	statsArr, err = handler.GetStatisticsInBlocks(liveTestTime.Add(time.Hour*(24*-7)), liveTestTime, time.Hour*25)
 	assert.Error(t, err)
 }
@@ -0,0 +1,135 @@
+package carbonintensitytest
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	carbonintensity "github.com/AlexCrane/uk-grid-carbon-intensity"
+)
+
+// Period is the data served for a single 30 minute settlement period, for either the whole country or a single region
+//
+// Forecast and Actual are in units of gCO2/KWh, with Actual set to -1 if it isn't known.
+// The Server never serves Actual for periods which haven't ended yet, or for regions (as the real API doesn't).
+// If Index is carbonintensity.IndexUnknown it is calculated from the intensity using carbonintensity.ClassifyIntensity.
+// The From and To of GenerationMix are ignored.
+type Period struct {
+	Forecast      int
+	Actual        int
+	Index         carbonintensity.IntensityIndex
+	GenerationMix carbonintensity.GenerationMix
+}
+
+// DataFunc returns the Period for the settlement period starting at from, for the region given by regionID,
+// or for the whole country if regionID is 0
+type DataFunc func(regionID int, from time.Time) Period
+
+// Region describes one of the regions of the API
+type Region struct {
+	ID        int
+	DNORegion string
+	ShortName string
+}
+
+// Regions are the regions served by the Server, indexed by region ID - 1
+var Regions = []Region{
+	{1, "Scottish Hydro Electric Power Distribution", "North Scotland"},
+	{2, "SP Distribution", "South Scotland"},
+	{3, "Electricity North West", "North West England"},
+	{4, "NPG North East", "North East England"},
+	{5, "NPG Yorkshire", "Yorkshire"},
+	{6, "SP Manweb", "North Wales & Merseyside"},
+	{7, "WPD South Wales", "South Wales"},
+	{8, "WPD West Midlands", "West Midlands"},
+	{9, "WPD East Midlands", "East Midlands"},
+	{10, "UKPN East", "East England"},
+	{11, "WPD South West", "South West England"},
+	{12, "SSE South", "South England"},
+	{13, "UKPN London", "London"},
+	{14, "UKPN South East", "South East England"},
+	{15, "England", "England"},
+	{16, "Scotland", "Scotland"},
+	{17, "Wales", "Wales"},
+}
+
+// DefaultFactors are the intensity factors served by the Server unless WithFactors is used
+var DefaultFactors = carbonintensity.IntensityFactors{
+	Biomass:          120,
+	Coal:             937,
+	DutchImports:     474,
+	FrenchImports:    53,
+	GasCombinedCycle: 394,
+	GasOpenCycle:     651,
+	Hydro:            0,
+	IrishImports:     458,
+	Nuclear:          0,
+	Oil:              935,
+	Other:            300,
+	PumpedStorage:    0,
+	Solar:            0,
+	Wind:             0,
+}
+
+// DefaultData is the DataFunc used by the Server unless WithData is used
+//
+// The data is a deterministic daily cycle, lowest overnight and highest in the early evening, which varies by day and by region.
+// Actual differs from Forecast by up to 5 gCO2/KWh. The generation mix is made up to roughly match the intensity.
+func DefaultData(regionID int, from time.Time) Period {
+	from = from.UTC()
+	halfHour := from.Hour()*2 + from.Minute()/30
+	day := from.YearDay()
+
+	forecast := 180 + int(math.Round(70*math.Sin(2*math.Pi*float64(halfHour-22)/48))) + 5*(day%7) + 3*regionID
+
+	solar := 0.0
+	if halfHour > 12 && halfHour < 36 {
+		solar = round1(10 * math.Sin(math.Pi*float64(halfHour-12)/24))
+	}
+
+	gas := round1(math.Min(math.Max(float64(forecast-50)/4, 0), 50))
+
+	mix := carbonintensity.GenerationMix{
+		Biomass: 6,
+		Imports: 8,
+		Gas:     gas,
+		Nuclear: 18,
+		Other:   1,
+		Hydro:   2,
+		Solar:   solar,
+	}
+	mix.Wind = round1(100 - mix.Biomass - mix.Imports - mix.Gas - mix.Nuclear - mix.Other - mix.Hydro - mix.Solar)
+
+	return Period{
+		Forecast:      forecast,
+		Actual:        forecast + (halfHour*7+day)%11 - 5,
+		GenerationMix: mix,
+	}
+}
+
+func round1(val float64) float64 {
+	return math.Round(val*10) / 10
+}
+
+// regionForPostcode returns the ID of the region containing postcode, unless WithPostcodeRegion says otherwise this is
+// chosen deterministically from the DNO regions (1 to 14)
+func (s *Server) regionForPostcode(postcode string) int {
+	if regionID, ok := s.postcodes[postcode]; ok {
+		return regionID
+	}
+
+	sum := 0
+	for _, c := range postcode {
+		sum += int(c)
+	}
+
+	return sum%14 + 1
+}
+
+func region(regionID int) (Region, error) {
+	if regionID < 1 || regionID > len(Regions) {
+		return Region{}, fmt.Errorf("Invalid region ID %d", regionID)
+	}
+
+	return Regions[regionID-1], nil
+}
@@ -0,0 +1,462 @@
+package carbonintensitytest
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	carbonintensity "github.com/AlexCrane/uk-grid-carbon-intensity"
+)
+
+// The time formats accepted for times in the path of a request
+var requestTimeFormats = []string{"2006-01-02T15:04Z07:00", time.RFC3339, "2006-01-02"}
+
+func parseTime(value string) (time.Time, *apiError) {
+	for _, layout := range requestTimeFormats {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, badRequest("Please enter a valid date in ISO8601 format, %q is not valid", value)
+}
+
+func floorPeriod(t time.Time) time.Time {
+	return t.UTC().Truncate(settlementPeriod)
+}
+
+// periodStarts returns the start of each settlement period from the one containing from, up to to
+func periodStarts(from time.Time, to time.Time) []time.Time {
+	var starts []time.Time
+	for start := floorPeriod(from); start.Before(to); start = start.Add(settlementPeriod) {
+		starts = append(starts, start)
+	}
+
+	return starts
+}
+
+// parseRange returns the range given by the from and to segments of a path, where to may be "fw24h", "fw48h" or "pt24h"
+func parseRange(fromSegment string, toSegment string) (time.Time, time.Time, *apiError) {
+	from, apiErr := parseTime(fromSegment)
+	if apiErr != nil {
+		return time.Time{}, time.Time{}, apiErr
+	}
+
+	switch toSegment {
+	case "fw24h":
+		return from, from.Add(24 * time.Hour), nil
+	case "fw48h":
+		return from, from.Add(48 * time.Hour), nil
+	case "pt24h":
+		return from.Add(-24 * time.Hour), from, nil
+	}
+
+	to, apiErr := parseTime(toSegment)
+	if apiErr != nil {
+		return time.Time{}, time.Time{}, apiErr
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, badRequest("The from date must be before the to date")
+	}
+
+	if to.Sub(from) > maxRange {
+		return time.Time{}, time.Time{}, badRequest("The date range must not be more than 30 days")
+	}
+
+	return from, to, nil
+}
+
+// dayPeriodStarts returns the start of each settlement period of the UK day given by date
+func dayPeriodStarts(date time.Time) []time.Time {
+	year, month, day := date.Date()
+	start := time.Date(year, month, day, 0, 0, 0, 0, london)
+	return periodStarts(start, start.AddDate(0, 0, 1))
+}
+
+// period returns the data to serve for the settlement period starting at from, for the region given by regionID
+// (or the whole country if regionID is 0)
+func (s *Server) period(regionID int, from time.Time) Period {
+	period, ok := s.overrides[periodKey{regionID: regionID, from: from}]
+	if !ok {
+		period = s.data(regionID, from)
+	}
+
+	if regionID != 0 || from.Add(settlementPeriod).After(s.now()) {
+		period.Actual = -1
+	}
+
+	if period.Index == carbonintensity.IndexUnknown {
+		value := period.Forecast
+		if period.Actual != -1 {
+			value = period.Actual
+		}
+
+		period.Index = carbonintensity.ClassifyIntensity(float64(value), from.Year())
+	}
+
+	return period
+}
+
+// optionalValue returns val, or nil (i.e. null) if it is -1
+func optionalValue(val int) interface{} {
+	if val == -1 {
+		return nil
+	}
+
+	return val
+}
+
+func (s *Server) intensityEntry(from time.Time) map[string]interface{} {
+	period := s.period(0, from)
+
+	return map[string]interface{}{
+		"from": from.Format(apiTimeFormat),
+		"to":   from.Add(settlementPeriod).Format(apiTimeFormat),
+		"intensity": map[string]interface{}{
+			"forecast": optionalValue(period.Forecast),
+			"actual":   optionalValue(period.Actual),
+			"index":    period.Index.String(),
+		},
+	}
+}
+
+func (s *Server) intensityEntries(starts []time.Time) []interface{} {
+	entries := make([]interface{}, 0, len(starts))
+	for _, start := range starts {
+		entries = append(entries, s.intensityEntry(start))
+	}
+
+	return entries
+}
+
+// statisticsEntry returns the statistics of the best known intensity (actual, otherwise forecast) of each period between from and to
+func (s *Server) statisticsEntry(from time.Time, to time.Time) map[string]interface{} {
+	highest, lowest, sum, count := 0, 0, 0, 0
+	for _, start := range periodStarts(from, to) {
+		period := s.period(0, start)
+
+		value := period.Forecast
+		if period.Actual != -1 {
+			value = period.Actual
+		}
+
+		if count == 0 || value > highest {
+			highest = value
+		}
+
+		if count == 0 || value < lowest {
+			lowest = value
+		}
+
+		sum += value
+		count++
+	}
+
+	average := int(math.Round(float64(sum) / float64(count)))
+
+	return map[string]interface{}{
+		"from": from.UTC().Format(apiTimeFormat),
+		"to":   to.UTC().Format(apiTimeFormat),
+		"intensity": map[string]interface{}{
+			"max":     highest,
+			"average": average,
+			"min":     lowest,
+			"index":   carbonintensity.ClassifyIntensity(float64(average), from.Year()).String(),
+		},
+	}
+}
+
+func (s *Server) routeIntensity(segments []string) (interface{}, *apiError) {
+	if len(segments) == 0 {
+		return s.intensityEntries([]time.Time{floorPeriod(s.now())}), nil
+	}
+
+	switch segments[0] {
+	case "date":
+		return s.routeIntensityDate(segments[1:])
+	case "factors":
+		if len(segments) != 1 {
+			return nil, errNotFound
+		}
+
+		return []interface{}{s.factors}, nil
+	case "stats":
+		return s.routeStatistics(segments[1:])
+	}
+
+	switch len(segments) {
+	case 1:
+		at, apiErr := parseTime(segments[0])
+		if apiErr != nil {
+			return nil, apiErr
+		}
+
+		return s.intensityEntries([]time.Time{floorPeriod(at)}), nil
+	case 2:
+		from, to, apiErr := parseRange(segments[0], segments[1])
+		if apiErr != nil {
+			return nil, apiErr
+		}
+
+		return s.intensityEntries(periodStarts(from, to)), nil
+	}
+
+	return nil, errNotFound
+}
+
+func (s *Server) routeIntensityDate(segments []string) (interface{}, *apiError) {
+	if len(segments) == 0 {
+		return s.intensityEntries(dayPeriodStarts(s.now().In(london))), nil
+	}
+
+	date, err := time.Parse("2006-01-02", segments[0])
+	if err != nil {
+		return nil, badRequest("Please enter a valid date in ISO8601 format YYYY-MM-DD, %q is not valid", segments[0])
+	}
+
+	starts := dayPeriodStarts(date)
+
+	switch len(segments) {
+	case 1:
+		return s.intensityEntries(starts), nil
+	case 2:
+		number, err := strconv.Atoi(segments[1])
+		if err != nil || number < 1 || number > len(starts) {
+			return nil, badRequest("Please enter a valid settlement period between 1 and %d", len(starts))
+		}
+
+		return s.intensityEntries(starts[number-1 : number]), nil
+	}
+
+	return nil, errNotFound
+}
+
+func (s *Server) routeStatistics(segments []string) (interface{}, *apiError) {
+	if len(segments) != 2 && len(segments) != 3 {
+		return nil, errNotFound
+	}
+
+	from, to, apiErr := parseRange(segments[0], segments[1])
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	if len(segments) == 2 {
+		return []interface{}{s.statisticsEntry(from, to)}, nil
+	}
+
+	blockHours, err := strconv.Atoi(segments[2])
+	if err != nil || blockHours < 1 || blockHours > 24 {
+		return nil, badRequest("Please enter a valid block size between 1 and 24 hours")
+	}
+
+	var entries []interface{}
+	for blockFrom := from; blockFrom.Before(to); blockFrom = blockFrom.Add(time.Duration(blockHours) * time.Hour) {
+		blockTo := blockFrom.Add(time.Duration(blockHours) * time.Hour)
+		if blockTo.After(to) {
+			blockTo = to
+		}
+
+		entries = append(entries, s.statisticsEntry(blockFrom, blockTo))
+	}
+
+	return entries, nil
+}
+
+type fuelPerc struct {
+	fuel string
+	perc float64
+}
+
+// generationMix returns mix in the form used by the API, including storage only if it is for a region
+func generationMix(mix carbonintensity.GenerationMix, regional bool) []interface{} {
+	fuels := []fuelPerc{
+		{"biomass", mix.Biomass}, {"coal", mix.Coal}, {"imports", mix.Imports}, {"gas", mix.Gas}, {"nuclear", mix.Nuclear},
+		{"other", mix.Other}, {"hydro", mix.Hydro}, {"solar", mix.Solar}, {"wind", mix.Wind},
+	}
+
+	if regional {
+		fuels = append(fuels, fuelPerc{"storage", mix.Storage})
+	}
+
+	entries := make([]interface{}, 0, len(fuels))
+	for _, fuel := range fuels {
+		entries = append(entries, map[string]interface{}{"fuel": fuel.fuel, "perc": fuel.perc})
+	}
+
+	return entries
+}
+
+func (s *Server) generationEntry(from time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"from":          from.Format(apiTimeFormat),
+		"to":            from.Add(settlementPeriod).Format(apiTimeFormat),
+		"generationmix": generationMix(s.period(0, from).GenerationMix, false),
+	}
+}
+
+func (s *Server) routeGeneration(segments []string) (interface{}, *apiError) {
+	switch len(segments) {
+	case 0:
+		// The current generation mix is a single object, rather than a list of one
+		return s.generationEntry(floorPeriod(s.now())), nil
+	case 2:
+		from, to, apiErr := parseRange(segments[0], segments[1])
+		if apiErr != nil {
+			return nil, apiErr
+		}
+
+		var entries []interface{}
+		for _, start := range periodStarts(from, to) {
+			entries = append(entries, s.generationEntry(start))
+		}
+
+		return entries, nil
+	}
+
+	return nil, errNotFound
+}
+
+// regionalValues returns the intensity and generation mix of a region for the settlement period starting at from
+func (s *Server) regionalValues(regionID int, from time.Time, entry map[string]interface{}) map[string]interface{} {
+	period := s.period(regionID, from)
+
+	entry["intensity"] = map[string]interface{}{
+		"forecast": optionalValue(period.Forecast),
+		"index":    period.Index.String(),
+	}
+	entry["generationmix"] = generationMix(period.GenerationMix, true)
+
+	return entry
+}
+
+func regionInfo(region Region, postcode string) map[string]interface{} {
+	info := map[string]interface{}{
+		"regionid":  region.ID,
+		"dnoregion": region.DNORegion,
+		"shortname": region.ShortName,
+	}
+
+	if postcode != "" {
+		info["postcode"] = postcode
+	}
+
+	return info
+}
+
+// allRegionsEntries returns a list of periods, each with a list of the values of every region
+func (s *Server) allRegionsEntries(starts []time.Time) []interface{} {
+	entries := make([]interface{}, 0, len(starts))
+	for _, start := range starts {
+		regions := make([]interface{}, 0, len(Regions))
+		for _, region := range Regions {
+			regions = append(regions, s.regionalValues(region.ID, start, regionInfo(region, "")))
+		}
+
+		entries = append(entries, map[string]interface{}{
+			"from":    start.Format(apiTimeFormat),
+			"to":      start.Add(settlementPeriod).Format(apiTimeFormat),
+			"regions": regions,
+		})
+	}
+
+	return entries
+}
+
+// regionEntries returns a single region, with a list of its values for each period
+func (s *Server) regionEntries(region Region, postcode string, starts []time.Time) []interface{} {
+	periods := make([]interface{}, 0, len(starts))
+	for _, start := range starts {
+		periods = append(periods, s.regionalValues(region.ID, start, map[string]interface{}{
+			"from": start.Format(apiTimeFormat),
+			"to":   start.Add(settlementPeriod).Format(apiTimeFormat),
+		}))
+	}
+
+	entry := regionInfo(region, postcode)
+	entry["data"] = periods
+
+	return []interface{}{entry}
+}
+
+// parseRegion returns the region given by the last two segments of a regional resource, e.g. "postcode" "RG10",
+// and the postcode if it was given by postcode
+func (s *Server) parseRegion(kind string, value string) (Region, string, *apiError) {
+	switch kind {
+	case "postcode":
+		if !outwardPostcodeRegexp.MatchString(value) {
+			return Region{}, "", badRequest("Please enter a valid outward postcode, %q is not valid", value)
+		}
+
+		region, err := region(s.regionForPostcode(value))
+		if err != nil {
+			return Region{}, "", badRequest("%s", err)
+		}
+
+		return region, value, nil
+	case "regionid":
+		regionID, err := strconv.Atoi(value)
+		if err != nil {
+			return Region{}, "", badRequest("Please enter a valid region ID, %q is not valid", value)
+		}
+
+		region, err := region(regionID)
+		if err != nil {
+			return Region{}, "", badRequest("%s", err)
+		}
+
+		return region, "", nil
+	}
+
+	return Region{}, "", errNotFound
+}
+
+func (s *Server) routeRegional(segments []string) (interface{}, *apiError) {
+	current := []time.Time{floorPeriod(s.now())}
+
+	if len(segments) == 0 {
+		return s.allRegionsEntries(current), nil
+	}
+
+	switch strings.Join(segments, "/") {
+	case "england":
+		return s.regionEntries(Regions[14], "", current), nil
+	case "scotland":
+		return s.regionEntries(Regions[15], "", current), nil
+	case "wales":
+		return s.regionEntries(Regions[16], "", current), nil
+	}
+
+	if len(segments) == 2 {
+		region, postcode, apiErr := s.parseRegion(segments[0], segments[1])
+		if apiErr != nil {
+			return nil, apiErr
+		}
+
+		return s.regionEntries(region, postcode, current), nil
+	}
+
+	if segments[0] != "intensity" || (len(segments) != 3 && len(segments) != 5) {
+		return nil, errNotFound
+	}
+
+	from, to, apiErr := parseRange(segments[1], segments[2])
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	starts := periodStarts(from, to)
+
+	if len(segments) == 3 {
+		return s.allRegionsEntries(starts), nil
+	}
+
+	region, postcode, apiErr := s.parseRegion(segments[3], segments[4])
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	return s.regionEntries(region, postcode, starts), nil
+}
@@ -0,0 +1,341 @@
+// Package carbonintensitytest provides a fake of the national grid carbon intensity API, for testing without network access
+//
+// A Server implements all of the /intensity, /intensity/stats, /intensity/factors, /regional and /generation resources
+// used by carbonintensity.APIHandler, serving deterministic data which can be replaced (see WithData and SetPeriod).
+// Errors, malformed responses and latency can be injected with AddFault and SetLatency, and the requests made are recorded.
+//
+//	server := carbonintensitytest.NewServer(carbonintensitytest.WithClock(func() time.Time { return now }))
+//	defer server.Close()
+//
+//	handler := server.APIHandler()
+//	intensity, err := handler.GetCurrentIntensity()
+package carbonintensitytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	// The London timezone is needed for the days of the /intensity/date resources, whether or not the system has it
+	_ "time/tzdata"
+
+	carbonintensity "github.com/AlexCrane/uk-grid-carbon-intensity"
+)
+
+const (
+	apiTimeFormat    = "2006-01-02T15:04Z"
+	settlementPeriod = 30 * time.Minute
+
+	// The longest range the API accepts
+	maxRange = 30 * 24 * time.Hour
+)
+
+var london = mustLoadLocation("Europe/London")
+
+var outwardPostcodeRegexp = regexp.MustCompile(`^[A-Z]{1,2}[0-9][A-Z0-9]?$`)
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return location
+}
+
+// Option configures a Server, see NewServer
+type Option func(*Server)
+
+// WithData makes the Server serve the data returned by data, rather than DefaultData
+func WithData(data DataFunc) Option {
+	return func(s *Server) {
+		s.data = data
+	}
+}
+
+// WithFactors makes the Server serve factors from /intensity/factors, rather than DefaultFactors
+func WithFactors(factors carbonintensity.IntensityFactors) Option {
+	return func(s *Server) {
+		s.factors = factors
+	}
+}
+
+// WithClock makes the Server use now to get the current time, rather than time.Now
+//
+// The current time decides the current settlement period and day, and which periods have Actual values.
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+// WithLatency makes the Server wait for latency before responding to each request
+func WithLatency(latency time.Duration) Option {
+	return func(s *Server) {
+		s.latency = latency
+	}
+}
+
+// WithPostcodeRegion makes the Server treat the outward postcode as being in the region given by regionID
+//
+// Postcodes without a region set are given one of the DNO regions (1 to 14) deterministically.
+func WithPostcodeRegion(postcode string, regionID int) Option {
+	return func(s *Server) {
+		s.postcodes[postcode] = regionID
+	}
+}
+
+// Fault is an error injected into the responses of a Server, see AddFault
+//
+// Path selects the requests affected; a request is affected if its path starts with Path, so "" affects all requests.
+// Count limits how many requests are affected, 0 means every request is.
+//
+// If StatusCode is set the response is an error from the API with that status and Message, with a Retry-After header if
+// RetryAfter is set. Otherwise if Body is set it is served as the body of a successful response (e.g. to serve malformed JSON),
+// with ContentType if set, otherwise as JSON. Delay is waited before responding, in addition to the latency of the Server.
+type Fault struct {
+	Path        string
+	Count       int
+	StatusCode  int
+	Message     string
+	RetryAfter  time.Duration
+	Body        string
+	ContentType string
+	Delay       time.Duration
+}
+
+// Request is a request received by a Server
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Time   time.Time
+}
+
+// Server is a fake of the carbon intensity API, running on a local httptest.Server
+type Server struct {
+	*httptest.Server
+
+	mutex     sync.Mutex
+	data      DataFunc
+	overrides map[periodKey]Period
+	factors   carbonintensity.IntensityFactors
+	postcodes map[string]int
+	now       func() time.Time
+	latency   time.Duration
+	faults    []*Fault
+	requests  []Request
+}
+
+type periodKey struct {
+	regionID int
+	from     time.Time
+}
+
+// apiError is an error response for a request, in the form the API reports it
+type apiError struct {
+	statusCode int
+	message    string
+}
+
+// NewServer starts and returns a Server, which should be closed with Close when finished with
+func NewServer(options ...Option) *Server {
+	s := &Server{
+		data:      DefaultData,
+		overrides: make(map[periodKey]Period),
+		factors:   DefaultFactors,
+		postcodes: make(map[string]int),
+		now:       time.Now,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// APIHandler returns a carbonintensity.APIHandler which makes its requests of the Server. Any options are applied after those
+// pointing it at the Server.
+func (s *Server) APIHandler(options ...carbonintensity.Option) *carbonintensity.APIHandler {
+	return carbonintensity.NewCarbonIntensityAPIHandler(append([]carbonintensity.Option{
+		carbonintensity.WithHTTPClient(s.Client()),
+		carbonintensity.WithBaseURL(s.URL),
+	}, options...)...)
+}
+
+// SetPeriod replaces the data for the settlement period starting at from, for the region given by regionID
+// (or the whole country if regionID is 0)
+func (s *Server) SetPeriod(regionID int, from time.Time, period Period) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.overrides[periodKey{regionID: regionID, from: from.UTC()}] = period
+}
+
+// SetLatency sets how long the Server waits before responding to each request
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.latency = latency
+}
+
+// AddFault injects fault into the responses of the Server. Faults are checked in the order they were added,
+// and only the first fault affecting a request is used.
+func (s *Server) AddFault(fault *Fault) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.faults = append(s.faults, fault)
+}
+
+// ClearFaults removes all of the faults added by AddFault
+func (s *Server) ClearFaults() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.faults = nil
+}
+
+// Requests returns the requests received by the Server, in the order they were received
+func (s *Server) Requests() []Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// ClearRequests forgets the requests received by the Server so far
+func (s *Server) ClearRequests() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requests = nil
+}
+
+// takeFault records r, returning the fault which affects it (if any) and the latency of the server
+func (s *Server) takeFault(r *http.Request) (*Fault, time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Time:   s.now(),
+	})
+
+	for i, fault := range s.faults {
+		if !strings.HasPrefix(r.URL.Path, fault.Path) {
+			continue
+		}
+
+		if fault.Count > 0 {
+			fault.Count--
+			if fault.Count == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+
+		return fault, s.latency
+	}
+
+	return nil, s.latency
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	fault, delay := s.takeFault(r)
+	if fault != nil {
+		delay += fault.Delay
+	}
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timer.C:
+		}
+	}
+
+	if fault != nil && fault.StatusCode != 0 {
+		if fault.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(fault.RetryAfter.Seconds())))
+		}
+
+		writeError(w, &apiError{statusCode: fault.StatusCode, message: fault.Message})
+		return
+	}
+
+	if fault != nil && fault.Body != "" {
+		contentType := fault.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(fault.Body))
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		writeError(w, &apiError{statusCode: http.StatusMethodNotAllowed, message: "Only GET requests are supported"})
+		return
+	}
+
+	s.mutex.Lock()
+	data, apiErr := s.route(strings.Split(strings.Trim(r.URL.Path, "/"), "/"))
+	s.mutex.Unlock()
+
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func writeError(w http.ResponseWriter, apiErr *apiError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.statusCode)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{
+			"code":    fmt.Sprintf("%d %s", apiErr.statusCode, http.StatusText(apiErr.statusCode)),
+			"message": apiErr.message,
+		},
+	})
+}
+
+func badRequest(format string, args ...interface{}) *apiError {
+	return &apiError{statusCode: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+var errNotFound = &apiError{statusCode: http.StatusNotFound, message: "Resource not found"}
+
+// route returns the data of the response for the resource with the path given by segments
+func (s *Server) route(segments []string) (interface{}, *apiError) {
+	switch segments[0] {
+	case "intensity":
+		return s.routeIntensity(segments[1:])
+	case "regional":
+		return s.routeRegional(segments[1:])
+	case "generation":
+		return s.routeGeneration(segments[1:])
+	}
+
+	return nil, errNotFound
+}
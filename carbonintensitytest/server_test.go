@@ -0,0 +1,311 @@
+package carbonintensitytest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	carbonintensity "github.com/AlexCrane/uk-grid-carbon-intensity"
+	"github.com/stretchr/testify/assert"
+)
+
+var testNow = time.Date(2018, 6, 20, 12, 10, 0, 0, time.UTC)
+
+func newTestServer(options ...Option) *Server {
+	return NewServer(append([]Option{WithClock(func() time.Time { return testNow })}, options...)...)
+}
+
+func TestIntensityResources(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	handler := server.APIHandler()
+	currentPeriod := time.Date(2018, 6, 20, 12, 0, 0, 0, time.UTC)
+
+	intensity, err := handler.GetCurrentIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, currentPeriod, intensity.From)
+	assert.Equal(t, currentPeriod.Add(30*time.Minute), intensity.To)
+	assert.False(t, intensity.HasActual())
+	assert.Equal(t, DefaultData(0, currentPeriod).Forecast, intensity.Forecast)
+	assert.Equal(t, carbonintensity.ClassifyIntensity(float64(intensity.Forecast), 2018), intensity.Index)
+
+	intensity, err = handler.GetIntensityForTimePeriod(currentPeriod.Add(-50 * time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, currentPeriod.Add(-time.Hour), intensity.From)
+	assert.True(t, intensity.HasActual())
+	assert.Equal(t, DefaultData(0, intensity.From).Actual, intensity.Actual)
+
+	// The day follows UK time, so starts at 23:00 UTC during BST
+	intensityArr, err := handler.GetTodaysIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, 48, len(intensityArr))
+	assert.Equal(t, time.Date(2018, 6, 19, 23, 0, 0, 0, time.UTC), intensityArr[0].From)
+
+	// Clock change days have 46 or 50 settlement periods
+	intensityArr, err = handler.GetIntensityForDay(time.Date(2018, 3, 25, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 46, len(intensityArr))
+
+	intensityArr, err = handler.GetIntensityForDay(time.Date(2018, 10, 28, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 50, len(intensityArr))
+
+	intensity, err = handler.GetIntensityForDayAndSettlementPeriod(time.Date(2018, 1, 20, 0, 0, 0, 0, time.UTC), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2018, 1, 20, 1, 0, 0, 0, time.UTC), intensity.From)
+
+	intensityArr, err = handler.GetIntensityBetween(currentPeriod.Add(-2*time.Hour), currentPeriod.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 8, len(intensityArr))
+	assert.True(t, intensityArr[3].HasActual())
+	assert.False(t, intensityArr[4].HasActual())
+
+	intensityArr, err = handler.GetNext24HourIntensity(currentPeriod)
+	assert.NoError(t, err)
+	assert.Equal(t, 48, len(intensityArr))
+
+	intensityArr, err = handler.GetNext48HourIntensity(currentPeriod)
+	assert.NoError(t, err)
+	assert.Equal(t, 96, len(intensityArr))
+
+	intensityArr, err = handler.GetPrior24HourIntensity(currentPeriod)
+	assert.NoError(t, err)
+	assert.Equal(t, 48, len(intensityArr))
+	assert.Equal(t, currentPeriod.Add(-24*time.Hour), intensityArr[0].From)
+
+	factors, err := handler.GetIntensityFactors()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultFactors, *factors)
+
+	// Ranges of up to 30 days are accepted, as by the API, and longer ones rejected before the APIHandler would even send them
+	for days, status := range map[int]int{30: http.StatusOK, 31: http.StatusBadRequest} {
+		to := currentPeriod.AddDate(0, 0, days)
+		resp, err := server.Client().Get(server.URL + "/intensity/" + currentPeriod.Format(apiTimeFormat) + "/" + to.Format(apiTimeFormat))
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+			assert.Equal(t, status, resp.StatusCode, "%d days", days)
+		}
+	}
+}
+
+func TestStatisticsResources(t *testing.T) {
+	from := time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC)
+
+	server := newTestServer(WithData(func(regionID int, from time.Time) Period {
+		return Period{Forecast: 100 + from.Hour(), Actual: 200 + from.Hour()}
+	}))
+	defer server.Close()
+
+	handler := server.APIHandler()
+
+	stats, err := handler.GetStatistics(from, from.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 213, stats.Max)
+	assert.Equal(t, 212, stats.Min)
+	assert.Equal(t, 213, stats.Average)
+	assert.Equal(t, carbonintensity.IndexModerate, stats.Index)
+
+	statsArr, err := handler.GetStatisticsInBlocks(from, from.Add(5*time.Hour), 2*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(statsArr))
+	assert.Equal(t, 216, statsArr[2].Max)
+	assert.Equal(t, from.Add(5*time.Hour), statsArr[2].To)
+
+	// Future periods use the forecast
+	stats, err = handler.GetStatistics(testNow.Add(24*time.Hour), testNow.Add(25*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 112, stats.Average)
+}
+
+func TestRegionalResources(t *testing.T) {
+	server := newTestServer(WithPostcodeRegion("RG10", 12))
+	defer server.Close()
+
+	handler := server.APIHandler()
+	currentPeriod := time.Date(2018, 6, 20, 12, 0, 0, 0, time.UTC)
+
+	regionalArr, err := handler.GetRegionalIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, len(Regions), len(regionalArr))
+	for i, regional := range regionalArr {
+		assert.Equal(t, i+1, regional.RegionID)
+		assert.Equal(t, Regions[i].ShortName, regional.ShortName)
+		assert.Equal(t, currentPeriod, regional.Intensity.From)
+		assert.False(t, regional.Intensity.HasActual())
+		assert.Equal(t, DefaultData(regional.RegionID, currentPeriod).GenerationMix.Wind, regional.GenerationMix.Wind)
+	}
+
+	regional, err := handler.GetEnglandIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, 15, regional.RegionID)
+
+	regional, err = handler.GetScotlandIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, 16, regional.RegionID)
+
+	regional, err = handler.GetWalesIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, 17, regional.RegionID)
+
+	regional, err = handler.GetIntensityForRegion(13)
+	assert.NoError(t, err)
+	assert.Equal(t, "London", regional.ShortName)
+
+	regional, err = handler.GetIntensityForPostcode("rg10 9ab")
+	assert.NoError(t, err)
+	assert.Equal(t, 12, regional.RegionID)
+	assert.Equal(t, "RG10", regional.Postcode)
+
+	regionalArr, err = handler.GetIntensityBetweenForPostcode(currentPeriod, currentPeriod.Add(3*time.Hour), "RG10")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(regionalArr))
+	assert.Equal(t, currentPeriod.Add(150*time.Minute), regionalArr[5].Intensity.From)
+
+	regionalArr, err = handler.GetNext24HourIntensityForPostcode(currentPeriod, "RG10")
+	assert.NoError(t, err)
+	assert.Equal(t, 48, len(regionalArr))
+
+	regionalArr, err = handler.GetNext48HourIntensityForPostcode(currentPeriod, "RG10")
+	assert.NoError(t, err)
+	assert.Equal(t, 96, len(regionalArr))
+
+	regionalArr, err = handler.GetPrior24HourIntensityForPostcode(currentPeriod, "RG10")
+	assert.NoError(t, err)
+	assert.Equal(t, 48, len(regionalArr))
+}
+
+func TestGenerationResources(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	handler := server.APIHandler()
+	currentPeriod := time.Date(2018, 6, 20, 12, 0, 0, 0, time.UTC)
+
+	mix, err := handler.GetCurrentGenerationMix()
+	assert.NoError(t, err)
+	assert.Equal(t, currentPeriod, mix.From)
+
+	expected := DefaultData(0, currentPeriod).GenerationMix
+	assert.Equal(t, expected.Gas, mix.Gas)
+	assert.Equal(t, expected.Solar, mix.Solar)
+	assert.InDelta(t, 100, mix.Biomass+mix.Coal+mix.Imports+mix.Gas+mix.Nuclear+mix.Other+mix.Hydro+mix.Solar+mix.Wind, 0.01)
+
+	mixArr, err := handler.GetGenerationMixBetween(currentPeriod, currentPeriod.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(mixArr))
+
+	mixArr, err = handler.GetPrior24HourGenerationMix(currentPeriod)
+	assert.NoError(t, err)
+	assert.Equal(t, 48, len(mixArr))
+}
+
+func TestSetPeriod(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	handler := server.APIHandler()
+	currentPeriod := time.Date(2018, 6, 20, 12, 0, 0, 0, time.UTC)
+
+	server.SetPeriod(0, currentPeriod, Period{Forecast: 42, Actual: 40, Index: carbonintensity.IndexHigh})
+	server.SetPeriod(13, currentPeriod, Period{Forecast: 7})
+
+	// Actual isn't served until the period has ended
+	intensity, err := handler.GetCurrentIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, 42, intensity.Forecast)
+	assert.Equal(t, -1, intensity.Actual)
+	assert.Equal(t, carbonintensity.IndexHigh, intensity.Index)
+
+	regional, err := handler.GetIntensityForRegion(13)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, regional.Intensity.Forecast)
+	assert.Equal(t, carbonintensity.IndexVeryLow, regional.Intensity.Index)
+}
+
+func TestFaults(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	handler := server.APIHandler()
+
+	server.AddFault(&Fault{Path: "/intensity/factors", StatusCode: http.StatusServiceUnavailable, Message: "Down for maintenance",
+		RetryAfter: 2 * time.Second, Count: 1})
+	server.AddFault(&Fault{Path: "/generation", Body: `{"data":[`})
+	server.AddFault(&Fault{Path: "/regional", Body: "<html></html>", ContentType: "text/html"})
+
+	var apiErr *carbonintensity.APIError
+	_, err := handler.GetIntensityFactors()
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, "Down for maintenance", apiErr.Message)
+	assert.Equal(t, 2*time.Second, apiErr.RetryAfter)
+
+	// The fault only affected one request
+	_, err = handler.GetIntensityFactors()
+	assert.NoError(t, err)
+
+	_, err = handler.GetCurrentGenerationMix()
+	assert.True(t, errors.Is(err, carbonintensity.ErrUnexpectedResponse))
+
+	var responseErr *carbonintensity.ResponseError
+	_, err = handler.GetRegionalIntensity()
+	assert.True(t, errors.As(err, &responseErr))
+
+	server.ClearFaults()
+	_, err = handler.GetCurrentGenerationMix()
+	assert.NoError(t, err)
+
+	// The fake validates requests the same way as the API, including those the APIHandler would reject before making them
+	resp, err := server.Client().Get(server.URL + "/intensity/date/2018-03-25/47")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	server := newTestServer(WithLatency(50 * time.Millisecond))
+	defer server.Close()
+
+	handler := server.APIHandler()
+
+	start := time.Now()
+	_, err := handler.GetCurrentIntensity()
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	server.SetLatency(0)
+	server.AddFault(&Fault{Delay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = handler.GetCurrentIntensityContext(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestRequestRecording(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	handler := server.APIHandler(carbonintensity.WithUserAgent("test-agent"))
+
+	_, err := handler.GetCurrentIntensity()
+	assert.NoError(t, err)
+	_, err = handler.GetIntensityForRegion(3)
+	assert.NoError(t, err)
+
+	requests := server.Requests()
+	assert.Equal(t, 2, len(requests))
+	assert.Equal(t, "/intensity", requests[0].Path)
+	assert.Equal(t, http.MethodGet, requests[0].Method)
+	assert.Equal(t, "test-agent", requests[0].Header.Get("User-Agent"))
+	assert.Equal(t, testNow, requests[0].Time)
+	assert.Equal(t, "/regional/regionid/3", requests[1].Path)
+
+	server.ClearRequests()
+	assert.Equal(t, 0, len(server.Requests()))
+}
@@ -0,0 +1,375 @@
+package carbonintensity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrMissingPeriods is returned alongside the data when a chunked range has gaps, i.e. settlement periods the API didn't return data for
+var ErrMissingPeriods = errors.New("Missing settlement periods")
+
+// WithChunkConcurrency makes the APIHandler fetch up to concurrency chunks at once for the Chunked functions
+// (e.g. GetIntensityBetweenChunked). By default chunks are fetched one at a time.
+func WithChunkConcurrency(concurrency int) Option {
+	return func(ah *APIHandler) {
+		ah.chunkConcurrency = concurrency
+	}
+}
+
+type timeRange struct {
+	from time.Time
+	to   time.Time
+}
+
+// splitRange splits the range from to to into consecutive ranges no longer than chunkSize
+func splitRange(from time.Time, to time.Time, chunkSize time.Duration) []timeRange {
+	var chunks []timeRange
+	for chunkFrom := from; chunkFrom.Before(to); chunkFrom = chunkFrom.Add(chunkSize) {
+		chunkTo := chunkFrom.Add(chunkSize)
+		if chunkTo.After(to) {
+			chunkTo = to
+		}
+
+		chunks = append(chunks, timeRange{from: chunkFrom, to: chunkTo})
+	}
+
+	return chunks
+}
+
+// fetchChunks calls fetch for the index of each chunk, with at most concurrency calls in progress at once.
+// The first error stops any further calls and is returned.
+func fetchChunks(ctx context.Context, chunks []timeRange, concurrency int, fetch func(ctx context.Context, index int) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	indexes := make(chan int)
+	errs := make(chan error, len(chunks))
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency && worker < len(chunks); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				if err := fetch(ctx, index); err != nil {
+					errs <- err
+					cancel()
+				}
+			}
+		}()
+	}
+
+feed:
+	for index := range chunks {
+		select {
+		case indexes <- index:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	close(errs)
+
+	// Prefer the error which caused cancellation over errors due to it
+	if err, ok := <-errs; ok {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// chunkWarnings collects the warnings from chunks whose responses had entries skipped by lenient decoding,
+// so that those chunks don't stop the rest from being fetched
+type chunkWarnings struct {
+	mutex    sync.Mutex
+	warnings []*EntryError
+}
+
+// collect returns err, unless it is a *PartialResponseError in which case its warnings are collected and nil is returned
+func (cw *chunkWarnings) collect(err error) error {
+	var partialErr *PartialResponseError
+	if !errors.As(err, &partialErr) {
+		return err
+	}
+
+	cw.mutex.Lock()
+	defer cw.mutex.Unlock()
+
+	cw.warnings = append(cw.warnings, partialErr.Warnings...)
+	return nil
+}
+
+// err returns a *PartialResponseError holding the warnings of all chunks, if there are any
+func (cw *chunkWarnings) err() error {
+	if len(cw.warnings) == 0 {
+		return nil
+	}
+
+	return &PartialResponseError{Warnings: cw.warnings}
+}
+
+// isChunkWarning returns whether err is one which GetIntensityBetweenChunked returns along with its data
+func isChunkWarning(err error) bool {
+	var partialErr *PartialResponseError
+	return errors.Is(err, ErrMissingPeriods) || errors.As(err, &partialErr)
+}
+
+// missingRuns returns the runs of consecutive settlement periods, of those starting at periodStarts (in order), for which
+// complete doesn't return true
+func missingRuns(periodStarts []time.Time, complete func(from time.Time) bool) []timeRange {
+	var runs []timeRange
+	for _, from := range periodStarts {
+		if complete(from) {
+			continue
+		}
+
+		if len(runs) > 0 && runs[len(runs)-1].to.Equal(from) {
+			runs[len(runs)-1].to = from.Add(settlementPeriodDuration)
+			continue
+		}
+
+		runs = append(runs, timeRange{from: from, to: from.Add(settlementPeriodDuration)})
+	}
+
+	return runs
+}
+
+// checkChunkRange returns an error if from to to isn't a valid range for the Chunked functions
+func checkChunkRange(from time.Time, to time.Time) error {
+	if !from.Before(to) {
+		return fmt.Errorf("%w; from (%s) must be strictly earlier than to (%s)", ErrInvalidRange, from.String(), to.String())
+	}
+
+	return nil
+}
+
+// GetIntensityBetweenChunked returns an array of Intensity objects, for all 30 minute settlement periods between from and to
+//
+// Unlike GetIntensityBetween the date range isn't limited. Ranges over 30 days are split into 30 day chunks which are fetched separately
+// (concurrently if WithChunkConcurrency is used) and combined into a single array ordered by From.
+// Periods returned for more than one chunk are only included once.
+//
+// If the combined array has gaps between periods, it is returned along with an error wrapping ErrMissingPeriods.
+// If WithLenientDecoding is used and entries of any chunk were skipped, it is instead returned along with a *PartialResponseError
+// holding the warnings of all chunks (the Entry of each warning is the index within the response for its chunk).
+func (ah *APIHandler) GetIntensityBetweenChunked(from time.Time, to time.Time) ([]*Intensity, error) {
+	return ah.GetIntensityBetweenChunkedContext(context.Background(), from, to)
+}
+
+// GetIntensityBetweenChunkedContext is the same as GetIntensityBetweenChunked, but the requests are made with the context ctx
+func (ah *APIHandler) GetIntensityBetweenChunkedContext(ctx context.Context, from time.Time, to time.Time) ([]*Intensity, error) {
+	if err := checkChunkRange(from, to); err != nil {
+		return nil, err
+	}
+
+	chunks := splitRange(from, to, maxDateRange)
+	results := make([][]*Intensity, len(chunks))
+	warnings := &chunkWarnings{}
+
+	err := fetchChunks(ctx, chunks, ah.chunkConcurrency, func(ctx context.Context, index int) error {
+		entries, err := ah.GetIntensityBetweenContext(ctx, chunks[index].from, chunks[index].to)
+		results[index] = entries
+		return warnings.collect(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var combined []*Intensity
+	for _, entries := range results {
+		combined = append(combined, entries...)
+	}
+
+	sort.SliceStable(combined, func(i, j int) bool { return combined[i].From.Before(combined[j].From) })
+
+	deduplicated := make([]*Intensity, 0, len(combined))
+	for _, entry := range combined {
+		if len(deduplicated) > 0 && deduplicated[len(deduplicated)-1].From.Equal(entry.From) {
+			continue
+		}
+
+		deduplicated = append(deduplicated, entry)
+	}
+
+	// Skipped entries will leave gaps, but the warnings explain those better
+	if err := warnings.err(); err != nil {
+		return deduplicated, err
+	}
+
+	for i := 1; i < len(deduplicated); i++ {
+		if deduplicated[i].From.After(deduplicated[i-1].To) {
+			return deduplicated, fmt.Errorf("%w; no data between %s and %s", ErrMissingPeriods,
+				deduplicated[i-1].To.Format(natGridTimeFormat), deduplicated[i].From.Format(natGridTimeFormat))
+		}
+	}
+
+	return deduplicated, nil
+}
+
+// GetStatisticsChunked returns a Statistics object giving carbon intensity statistics for the period between from and to
+//
+// Unlike GetStatistics the date range isn't limited. Ranges over 30 days are split into 30 day chunks which are fetched separately
+// (concurrently if WithChunkConcurrency is used), and the statistics for the whole range are calculated from those of the chunks;
+// Max and Min are the largest and smallest of the chunks, Average is the average of the chunks weighted by their length.
+// Index rates the overall Average as ClassifyIntensity does, but with the bands of each year the range is in weighted by how much
+// of the range is in that year.
+func (ah *APIHandler) GetStatisticsChunked(from time.Time, to time.Time) (*Statistics, error) {
+	return ah.GetStatisticsChunkedContext(context.Background(), from, to)
+}
+
+// GetStatisticsChunkedContext is the same as GetStatisticsChunked, but the requests are made with the context ctx
+func (ah *APIHandler) GetStatisticsChunkedContext(ctx context.Context, from time.Time, to time.Time) (*Statistics, error) {
+	if err := checkChunkRange(from, to); err != nil {
+		return nil, err
+	}
+
+	chunks := splitRange(from, to, maxDateRange)
+	results := make([]*Statistics, len(chunks))
+
+	err := fetchChunks(ctx, chunks, ah.chunkConcurrency, func(ctx context.Context, index int) error {
+		stats, err := ah.GetStatisticsContext(ctx, chunks[index].from, chunks[index].to)
+		results[index] = stats
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return combineStatistics(results, chunks), nil
+}
+
+// yearWeightedBands returns the IndexBands of the years ranges are in, each weighted by how many hours of ranges are in it
+func yearWeightedBands(ranges []timeRange) IndexBands {
+	var weighted IndexBands
+	var totalHours float64
+	for _, r := range ranges {
+		for from := r.from.UTC(); from.Before(r.to); {
+			to := time.Date(from.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+			if r.to.Before(to) {
+				to = r.to.UTC()
+			}
+
+			hours := to.Sub(from).Hours()
+			bands := IndexBandsForYear(from.Year())
+			weighted.Low += bands.Low * hours
+			weighted.Moderate += bands.Moderate * hours
+			weighted.High += bands.High * hours
+			weighted.VeryHigh += bands.VeryHigh * hours
+			totalHours += hours
+
+			from = to
+		}
+	}
+
+	weighted.Low /= totalHours
+	weighted.Moderate /= totalHours
+	weighted.High /= totalHours
+	weighted.VeryHigh /= totalHours
+	return weighted
+}
+
+// combineStatistics calculates the statistics for the whole of chunks from the statistics for each chunk
+//
+// Values missing from the statistics of a chunk are ignored, and are only missing from the combined statistics if they are missing from every chunk.
+// The Index is that of the combined average, with the bands of each year the chunks with an average are in weighted by how much of
+// them is in that year, so that a range over several years isn't rated with the bands of its first year alone.
+func combineStatistics(results []*Statistics, chunks []timeRange) *Statistics {
+	combined := &Statistics{
+		From:    results[0].From,
+		To:      results[len(results)-1].To,
+		Max:     -1,
+		Average: -1,
+		Min:     -1,
+	}
+
+	var weightedSum float64
+	var totalWeight float64
+	var averaged []timeRange
+	for index, stats := range results {
+		if chunkMax, ok := stats.MaxValue(); ok && (combined.Max == -1 || chunkMax > combined.Max) {
+			combined.Max = chunkMax
+		}
+
+		if chunkMin, ok := stats.MinValue(); ok && (combined.Min == -1 || chunkMin < combined.Min) {
+			combined.Min = chunkMin
+		}
+
+		if chunkAverage, ok := stats.AverageValue(); ok {
+			weight := chunks[index].to.Sub(chunks[index].from).Hours()
+			weightedSum += float64(chunkAverage) * weight
+			totalWeight += weight
+			averaged = append(averaged, chunks[index])
+		}
+	}
+
+	if totalWeight == 0 {
+		return combined
+	}
+
+	combined.Average = int(math.Round(weightedSum / totalWeight))
+	combined.Index = yearWeightedBands(averaged).Classify(float64(combined.Average))
+
+	return combined
+}
+
+// GetStatisticsInBlocksChunked returns an array of Statistics object giving carbon intensity statistics for the period between from and to
+//
+// Each Statistic object in the array covers a period of time given by blockSize, which must be between 1 and 24 hours inclusive.
+//
+// Unlike GetStatisticsInBlocks the date range isn't limited. Ranges over 30 days are split into chunks of up to 30 days which are a
+// whole number of blocks long, so that no block is split between chunks. The chunks are fetched separately
+// (concurrently if WithChunkConcurrency is used) and combined into a single array ordered by From.
+// As with GetIntensityBetweenChunked, entries skipped by lenient decoding are reported by a *PartialResponseError.
+func (ah *APIHandler) GetStatisticsInBlocksChunked(from time.Time, to time.Time, blockSize time.Duration) ([]*Statistics, error) {
+	return ah.GetStatisticsInBlocksChunkedContext(context.Background(), from, to, blockSize)
+}
+
+// GetStatisticsInBlocksChunkedContext is the same as GetStatisticsInBlocksChunked, but the requests are made with the context ctx
+func (ah *APIHandler) GetStatisticsInBlocksChunkedContext(ctx context.Context, from time.Time, to time.Time, blockSize time.Duration) ([]*Statistics, error) {
+	if err := checkChunkRange(from, to); err != nil {
+		return nil, err
+	}
+
+	blockSizeHours := int(blockSize.Hours())
+
+	if blockSizeHours < 1 || blockSizeHours > 24 {
+		return nil, fmt.Errorf("%w %s; must be between 1 and 24 hours inclusive", ErrInvalidBlockSize, blockSize.String())
+	}
+
+	blockDuration := time.Duration(blockSizeHours) * time.Hour
+	chunks := splitRange(from, to, (maxDateRange/blockDuration)*blockDuration)
+	results := make([][]*Statistics, len(chunks))
+	warnings := &chunkWarnings{}
+
+	err := fetchChunks(ctx, chunks, ah.chunkConcurrency, func(ctx context.Context, index int) error {
+		entries, err := ah.GetStatisticsInBlocksContext(ctx, chunks[index].from, chunks[index].to, blockSize)
+		results[index] = entries
+		return warnings.collect(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var combined []*Statistics
+	for _, entries := range results {
+		for _, entry := range entries {
+			if len(combined) > 0 && !entry.From.After(combined[len(combined)-1].From) {
+				continue
+			}
+
+			combined = append(combined, entry)
+		}
+	}
+
+	return combined, warnings.err()
+}
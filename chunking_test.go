@@ -0,0 +1,190 @@
+package carbonintensity
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newChunkingTestServer returns a test server giving each period an actual intensity of its day of the month, leaving out
+// periods starting at missing
+func newChunkingTestServer(missing time.Time) *testPeriodServer {
+	return startTestPeriodServer(&testPeriodServer{
+		actual:  func(from time.Time) (int, bool) { return from.Day(), true },
+		missing: missing,
+	})
+}
+
+func TestSplitRange(t *testing.T) {
+	from := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	chunks := splitRange(from, from.Add(time.Hour), maxDateRange)
+	assert.Equal(t, []timeRange{{from: from, to: from.Add(time.Hour)}}, chunks)
+
+	chunks = splitRange(from, from.Add(maxDateRange), maxDateRange)
+	assert.Equal(t, 1, len(chunks))
+
+	chunks = splitRange(from, from.AddDate(1, 0, 0), maxDateRange)
+	assert.Equal(t, 13, len(chunks))
+	assert.Equal(t, from, chunks[0].from)
+	assert.Equal(t, from.AddDate(1, 0, 0), chunks[12].to)
+	for i := 1; i < len(chunks); i++ {
+		assert.Equal(t, chunks[i-1].to, chunks[i].from)
+	}
+}
+
+func TestIntensityBetweenChunked(t *testing.T) {
+	server := newChunkingTestServer(time.Time{})
+	defer server.Close()
+
+	from := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+
+	for _, concurrency := range []int{1, 4} {
+		requests := server.requestCount()
+		handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithChunkConcurrency(concurrency))
+
+		intensityArr, err := handler.GetIntensityBetweenChunked(from, to)
+		assert.NoError(t, err)
+		assert.Equal(t, requests+13, server.requestCount())
+
+		// One for each half hour of the year, plus the one ending at from
+		assert.Equal(t, 365*48+1, len(intensityArr))
+		assert.Equal(t, from.Add(-settlementPeriodDuration), intensityArr[0].From)
+		assert.Equal(t, to, intensityArr[len(intensityArr)-1].To)
+		for i := 1; i < len(intensityArr); i++ {
+			assert.Equal(t, intensityArr[i-1].To, intensityArr[i].From)
+		}
+	}
+
+	// Short ranges are a single request
+	requests := server.requestCount()
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL))
+	intensityArr, err := handler.GetIntensityBetweenChunked(from, from.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(intensityArr))
+	assert.Equal(t, requests+1, server.requestCount())
+
+	_, err = handler.GetIntensityBetweenChunked(from, from)
+	assert.True(t, errors.Is(err, ErrInvalidRange))
+}
+
+func TestIntensityBetweenChunkedGaps(t *testing.T) {
+	from := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	missing := from.AddDate(0, 2, 0)
+
+	server := newChunkingTestServer(missing)
+	defer server.Close()
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithChunkConcurrency(3))
+	intensityArr, err := handler.GetIntensityBetweenChunked(from, from.AddDate(0, 4, 0))
+	assert.True(t, errors.Is(err, ErrMissingPeriods))
+	assert.Contains(t, err.Error(), missing.Format(natGridTimeFormat))
+	assert.NotEmpty(t, intensityArr)
+}
+
+func TestIntensityBetweenChunkedErrors(t *testing.T) {
+	server := startTestPeriodServer(&testPeriodServer{failures: -1, failureStatus: http.StatusInternalServerError})
+	defer server.Close()
+
+	from := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithChunkConcurrency(2))
+	intensityArr, err := handler.GetIntensityBetweenChunked(from, from.AddDate(1, 0, 0))
+	assert.Nil(t, intensityArr)
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+
+	// The first error stops any more chunks being fetched
+	assert.True(t, server.requestCount() <= 3)
+}
+
+func TestStatisticsChunked(t *testing.T) {
+	server := newChunkingTestServer(time.Time{})
+	defer server.Close()
+
+	from := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithChunkConcurrency(2))
+
+	// 30 days from the 1st of January, then 15 days from the 31st of January
+	stats, err := handler.GetStatisticsChunked(from, from.AddDate(0, 0, 45))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, server.requestCount())
+	assert.Equal(t, from, stats.From)
+	assert.Equal(t, from.AddDate(0, 0, 45), stats.To)
+	assert.Equal(t, 131+31, stats.Max)
+	assert.Equal(t, 100, stats.Min)
+	assert.Equal(t, 111, stats.Average)
+	assert.Equal(t, IndexLow, stats.Index)
+
+	stats, err = handler.GetStatisticsChunked(from, from.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 101, stats.Average)
+}
+
+func TestCombineStatisticsMissingValues(t *testing.T) {
+	from := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	chunks := splitRange(from, from.AddDate(0, 0, 60), maxDateRange)
+
+	// Missing values in one chunk shouldn't be combined as if they were intensities of -1
+	stats := combineStatistics([]*Statistics{
+		{From: chunks[0].from, To: chunks[0].to, Max: 200, Average: 150, Min: 100, Index: IndexLow},
+		{From: chunks[1].from, To: chunks[1].to, Max: -1, Average: -1, Min: -1},
+	}, chunks)
+
+	assert.Equal(t, 200, stats.Max)
+	assert.Equal(t, 150, stats.Average)
+	assert.Equal(t, 100, stats.Min)
+	assert.Equal(t, IndexLow, stats.Index)
+
+	// The index is that of the combined average, not of either chunk
+	stats = combineStatistics([]*Statistics{
+		{From: chunks[0].from, To: chunks[0].to, Max: 150, Average: 100, Min: 50, Index: IndexLow},
+		{From: chunks[1].from, To: chunks[1].to, Max: 350, Average: 300, Min: 250, Index: IndexHigh},
+	}, chunks)
+	assert.Equal(t, 200, stats.Average)
+	assert.Equal(t, IndexModerate, stats.Index)
+
+	// Over several years the index uses the bands of each year, so 167 is rated Moderate by the average of the 2018 and 2019
+	// bands (165) although it is Low by the 2018 bands alone
+	yearsChunks := splitRange(from, from.AddDate(2, 0, 0), maxDateRange)
+	var yearsResults []*Statistics
+	for _, chunk := range yearsChunks {
+		yearsResults = append(yearsResults, &Statistics{From: chunk.from, To: chunk.to, Max: 200, Average: 167, Min: 100})
+	}
+	stats = combineStatistics(yearsResults, yearsChunks)
+	assert.Equal(t, 167, stats.Average)
+	assert.Equal(t, IndexModerate, stats.Index)
+	assert.Equal(t, IndexLow, ClassifyIntensity(167, 2018))
+
+	stats = combineStatistics([]*Statistics{{Max: -1, Average: -1, Min: -1}, {Max: -1, Average: -1, Min: -1}}, chunks)
+	_, ok := stats.AverageValue()
+	assert.False(t, ok)
+	assert.Equal(t, IndexUnknown, stats.Index)
+}
+
+func TestStatisticsInBlocksChunked(t *testing.T) {
+	server := newChunkingTestServer(time.Time{})
+	defer server.Close()
+
+	from := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 3, 0)
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL))
+
+	// 7 hours doesn't divide into 30 days, so blocks must not be split between chunks
+	statsArr, err := handler.GetStatisticsInBlocksChunked(from, to, 7*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int((to.Sub(from)+7*time.Hour-1)/(7*time.Hour)), len(statsArr))
+	for i, stats := range statsArr {
+		assert.Equal(t, from.Add(time.Duration(i)*7*time.Hour), stats.From)
+	}
+
+	_, err = handler.GetStatisticsInBlocksChunked(from, to, 25*time.Hour)
+	assert.True(t, errors.Is(err, ErrInvalidBlockSize))
+}
@@ -0,0 +1,483 @@
+// Command carbonintensity queries the national grid carbon intensity API from the command line
+//
+// Usage:
+//
+//	carbonintensity <command> [flags]
+//
+// The commands are:
+//
+//	current     intensity for the current settlement period
+//	at          intensity for the settlement period containing -time
+//	today       intensity for every settlement period of today
+//	day         intensity for every settlement period of -date, or just settlement period -period
+//	between     intensity for every settlement period between -from and -to
+//	forecast    intensity for the -hours (24 or 48) after -from
+//	prior       intensity for the 24 hours before -from
+//	stats       statistics for -from to -to, in blocks of -block if given
+//	factors     the intensity factors of each fuel type
+//	regional    intensity for every region, or for -region or -country
+//	postcode    intensity for the region containing -postcode, optionally for a range of time
+//	generation  generation mix for the current settlement period, or for a range of time
+//	window      the greenest time to start a job lasting -duration which must finish by -deadline
+//
+// Every command accepts -format (table, json or csv), -base-url and -timeout. Times are given as RFC 3339 (e.g. 2018-01-20T12:00Z),
+// as a date (e.g. 2018-01-20), as "now", or relative to now (e.g. -24h or +90m).
+//
+// The exit code is 0 on success, 1 for unexpected failures, 2 for invalid usage (including arguments rejected before making a request),
+// 3 for errors reported by the API, 4 for responses which couldn't be understood and 5 for failures making requests.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/AlexCrane/uk-grid-carbon-intensity"
+)
+
+// Exit codes for each kind of error
+const (
+	exitSuccess            = 0
+	exitFailure            = 1
+	exitUsage              = 2
+	exitAPIError           = 3
+	exitUnexpectedResponse = 4
+	exitRequestFailure     = 5
+)
+
+// The maximum range of the API resources which aren't chunked
+const maxDateRange = 30 * 24 * time.Hour
+
+// errUsage is returned for invalid command line arguments
+var errUsage = errors.New("Invalid usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// command is a subcommand of the tool. Its flags are added to flags, and run is called after they have been parsed.
+type command struct {
+	name    string
+	summary string
+	flags   func(flags *flag.FlagSet) func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error)
+}
+
+var commands = []command{
+	{"current", "intensity for the current settlement period", currentCommand},
+	{"at", "intensity for the settlement period containing -time", atCommand},
+	{"today", "intensity for every settlement period of today", todayCommand},
+	{"day", "intensity for every settlement period of -date, or just settlement period -period", dayCommand},
+	{"between", "intensity for every settlement period between -from and -to", betweenCommand},
+	{"forecast", "intensity for the -hours (24 or 48) after -from", forecastCommand},
+	{"prior", "intensity for the 24 hours before -from", priorCommand},
+	{"stats", "statistics for -from to -to, in blocks of -block if given", statsCommand},
+	{"factors", "the intensity factors of each fuel type", factorsCommand},
+	{"regional", "intensity for every region, or for -region or -country", regionalCommand},
+	{"postcode", "intensity for the region containing -postcode, optionally for a range of time", postcodeCommand},
+	{"generation", "generation mix for the current settlement period, or for a range of time", generationCommand},
+	{"window", "the greenest time to start a job lasting -duration which must finish by -deadline", windowCommand},
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: carbonintensity <command> [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", cmd.name, cmd.summary)
+	}
+
+	fmt.Fprintf(w, "\nRun 'carbonintensity <command> -h' for the flags of a command.\n")
+}
+
+// run runs the command line given by args, returning the exit code
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == args[0] {
+			cmd = &commands[i]
+		}
+	}
+
+	if cmd == nil {
+		if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			usage(stdout)
+			return exitSuccess
+		}
+
+		fmt.Fprintf(stderr, "Unknown command %q\n\n", args[0])
+		usage(stderr)
+		return exitUsage
+	}
+
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	format := flags.String("format", "table", "output format; table, json or csv")
+	baseURL := flags.String("base-url", "", "base URL of the API, if not the national grid server")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout for the whole command")
+	runCommand := cmd.flags(flags)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitSuccess
+		}
+
+		return exitUsage
+	}
+
+	if flags.NArg() != 0 {
+		fmt.Fprintf(stderr, "Unexpected arguments %q\n", flags.Args())
+		return exitUsage
+	}
+
+	writeOutput, ok := outputFormats[*format]
+	if !ok {
+		fmt.Fprintf(stderr, "Invalid -format %q; must be table, json or csv\n", *format)
+		return exitUsage
+	}
+
+	options := []carbonintensity.Option{carbonintensity.WithUserAgent("carbonintensity-cli")}
+	if *baseURL != "" {
+		options = append(options, carbonintensity.WithBaseURL(*baseURL))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	result, err := runCommand(ctx, carbonintensity.NewCarbonIntensityAPIHandler(options...))
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return exitCode(err)
+	}
+
+	if err := writeOutput(stdout, result); err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return exitFailure
+	}
+
+	return exitSuccess
+}
+
+// exitCode returns the exit code for the kind of error err is
+func exitCode(err error) int {
+	var postcodeErr *carbonintensity.PostcodeError
+	var apiErr *carbonintensity.APIError
+	var urlErr *url.Error
+
+	switch {
+	case errors.Is(err, errUsage),
+		errors.Is(err, carbonintensity.ErrInvalidRange),
+		errors.Is(err, carbonintensity.ErrRangeTooLarge),
+		errors.Is(err, carbonintensity.ErrInvalidSettlementPeriod),
+		errors.Is(err, carbonintensity.ErrInvalidBlockSize),
+		errors.Is(err, carbonintensity.ErrInvalidRegionID),
+		errors.As(err, &postcodeErr) && postcodeErr.Err == nil:
+		return exitUsage
+	case errors.As(err, &apiErr):
+		return exitAPIError
+	case errors.Is(err, carbonintensity.ErrUnexpectedResponse), errors.Is(err, carbonintensity.ErrMissingPeriods):
+		return exitUnexpectedResponse
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &urlErr):
+		return exitRequestFailure
+	}
+
+	return exitFailure
+}
+
+// timeFlag is a flag holding a time, see parseTime for the formats accepted
+type timeFlag struct {
+	value time.Time
+	set   bool
+}
+
+func (tf *timeFlag) String() string {
+	if !tf.set {
+		return ""
+	}
+
+	return tf.value.Format(time.RFC3339)
+}
+
+func (tf *timeFlag) Set(value string) error {
+	parsed, err := parseTime(value, time.Now())
+	if err != nil {
+		return err
+	}
+
+	tf.value = parsed
+	tf.set = true
+	return nil
+}
+
+// parseTime parses value as an RFC 3339 time (with or without seconds), a date, "now", or a duration relative to now
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if value == "now" {
+		return now, nil
+	}
+
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+") {
+		offset, err := time.ParseDuration(value)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		return now.Add(offset), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q; must be RFC 3339, a date, now or relative to now (e.g. -24h)", value)
+}
+
+// timeOrNow returns the time of tf, or the current time if it wasn't set
+func (tf *timeFlag) timeOrNow() time.Time {
+	if tf.set {
+		return tf.value
+	}
+
+	return time.Now()
+}
+
+func timeFlags(flags *flag.FlagSet, names ...string) []*timeFlag {
+	var tfs []*timeFlag
+	for _, name := range names {
+		tf := &timeFlag{}
+		flags.Var(tf, name, fmt.Sprintf("%s time; RFC 3339, a date, now or relative to now (e.g. -24h)", name))
+		tfs = append(tfs, tf)
+	}
+
+	return tfs
+}
+
+// requireRange returns an error unless both from and to were given
+func requireRange(from *timeFlag, to *timeFlag) error {
+	if !from.set || !to.set {
+		return fmt.Errorf("%w; -from and -to are required", errUsage)
+	}
+
+	return nil
+}
+
+func currentCommand(flags *flag.FlagSet) func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+	return func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+		return handler.GetCurrentIntensityContext(ctx)
+	}
+}
+
+func atCommand(flags *flag.FlagSet) func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+	at := timeFlags(flags, "time")[0]
+
+	return func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+		return handler.GetIntensityForTimePeriodContext(ctx, at.timeOrNow())
+	}
+}
+
+func todayCommand(flags *flag.FlagSet) func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+	return func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+		return handler.GetTodaysIntensityContext(ctx)
+	}
+}
+
+func dayCommand(flags *flag.FlagSet) func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+	date := timeFlags(flags, "date")[0]
+	period := flags.Int("period", 0, "settlement period of the day (1 to 48, or 46/50 on clock change days)")
+
+	return func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+		if !date.set {
+			return nil, fmt.Errorf("%w; -date is required", errUsage)
+		}
+
+		if *period != 0 {
+			return handler.GetIntensityForDayAndSettlementPeriodContext(ctx, date.value, *period)
+		}
+
+		return handler.GetIntensityForDayContext(ctx, date.value)
+	}
+}
+
+func betweenCommand(flags *flag.FlagSet) func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+	tfs := timeFlags(flags, "from", "to")
+
+	return func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+		from, to := tfs[0], tfs[1]
+		if err := requireRange(from, to); err != nil {
+			return nil, err
+		}
+
+		if to.value.Sub(from.value) > maxDateRange {
+			return handler.GetIntensityBetweenChunkedContext(ctx, from.value, to.value)
+		}
+
+		return handler.GetIntensityBetweenContext(ctx, from.value, to.value)
+	}
+}
+
+func forecastCommand(flags *flag.FlagSet) func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+	from := timeFlags(flags, "from")[0]
+	hours := flags.Int("hours", 24, "hours of forecast; 24 or 48")
+
+	return func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+		switch *hours {
+		case 24:
+			return handler.GetNext24HourIntensityContext(ctx, from.timeOrNow())
+		case 48:
+			return handler.GetNext48HourIntensityContext(ctx, from.timeOrNow())
+		}
+
+		return nil, fmt.Errorf("%w; -hours must be 24 or 48", errUsage)
+	}
+}
+
+func priorCommand(flags *flag.FlagSet) func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+	from := timeFlags(flags, "from")[0]
+
+	return func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+		return handler.GetPrior24HourIntensityContext(ctx, from.timeOrNow())
+	}
+}
+
+func statsCommand(flags *flag.FlagSet) func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+	tfs := timeFlags(flags, "from", "to")
+	block := flags.Duration("block", 0, "size of each block of statistics, between 1h and 24h")
+
+	return func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+		from, to := tfs[0], tfs[1]
+		if err := requireRange(from, to); err != nil {
+			return nil, err
+		}
+
+		chunked := to.value.Sub(from.value) > maxDateRange
+
+		switch {
+		case *block == 0 && chunked:
+			return handler.GetStatisticsChunkedContext(ctx, from.value, to.value)
+		case *block == 0:
+			return handler.GetStatisticsContext(ctx, from.value, to.value)
+		case chunked:
+			return handler.GetStatisticsInBlocksChunkedContext(ctx, from.value, to.value, *block)
+		}
+
+		return handler.GetStatisticsInBlocksContext(ctx, from.value, to.value, *block)
+	}
+}
+
+func factorsCommand(flags *flag.FlagSet) func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+	return func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+		return handler.GetIntensityFactorsContext(ctx)
+	}
+}
+
+func regionalCommand(flags *flag.FlagSet) func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+	regionID := flags.Int("region", 0, "region ID (1 to 17)")
+	country := flags.String("country", "", "england, scotland or wales")
+
+	return func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+		if *regionID != 0 && *country != "" {
+			return nil, fmt.Errorf("%w; only one of -region and -country can be given", errUsage)
+		}
+
+		if *regionID != 0 {
+			return handler.GetIntensityForRegionContext(ctx, *regionID)
+		}
+
+		switch *country {
+		case "":
+			return handler.GetRegionalIntensityContext(ctx)
+		case "england":
+			return handler.GetEnglandIntensityContext(ctx)
+		case "scotland":
+			return handler.GetScotlandIntensityContext(ctx)
+		case "wales":
+			return handler.GetWalesIntensityContext(ctx)
+		}
+
+		return nil, fmt.Errorf("%w; -country must be england, scotland or wales", errUsage)
+	}
+}
+
+func postcodeCommand(flags *flag.FlagSet) func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+	postcode := flags.String("postcode", "", "outward postcode, e.g. RG10")
+	tfs := timeFlags(flags, "from", "to")
+	forecastHours := flags.Int("forecast", 0, "hours of forecast after -from; 24 or 48")
+	prior := flags.Bool("prior", false, "the 24 hours before -from")
+
+	return func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+		from, to := tfs[0], tfs[1]
+		if *postcode == "" {
+			return nil, fmt.Errorf("%w; -postcode is required", errUsage)
+		}
+
+		switch {
+		case *prior:
+			return handler.GetPrior24HourIntensityForPostcodeContext(ctx, from.timeOrNow(), *postcode)
+		case *forecastHours == 24:
+			return handler.GetNext24HourIntensityForPostcodeContext(ctx, from.timeOrNow(), *postcode)
+		case *forecastHours == 48:
+			return handler.GetNext48HourIntensityForPostcodeContext(ctx, from.timeOrNow(), *postcode)
+		case *forecastHours != 0:
+			return nil, fmt.Errorf("%w; -forecast must be 24 or 48", errUsage)
+		case to.set:
+			if err := requireRange(from, to); err != nil {
+				return nil, err
+			}
+
+			return handler.GetIntensityBetweenForPostcodeContext(ctx, from.value, to.value, *postcode)
+		}
+
+		return handler.GetIntensityForPostcodeContext(ctx, *postcode)
+	}
+}
+
+func generationCommand(flags *flag.FlagSet) func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+	tfs := timeFlags(flags, "from", "to")
+	prior := flags.Bool("prior", false, "the 24 hours before -from")
+
+	return func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+		from, to := tfs[0], tfs[1]
+
+		switch {
+		case *prior:
+			return handler.GetPrior24HourGenerationMixContext(ctx, from.timeOrNow())
+		case to.set:
+			if err := requireRange(from, to); err != nil {
+				return nil, err
+			}
+
+			return handler.GetGenerationMixBetweenContext(ctx, from.value, to.value)
+		}
+
+		return handler.GetCurrentGenerationMixContext(ctx)
+	}
+}
+
+func windowCommand(flags *flag.FlagSet) func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+	duration := flags.Duration("duration", 0, "how long the job lasts")
+	deadline := timeFlags(flags, "deadline")[0]
+	alternatives := flags.Int("alternatives", 3, "number of alternative windows")
+	postcode := flags.String("postcode", "", "outward postcode, to use the forecast for its region")
+
+	return func(ctx context.Context, handler *carbonintensity.APIHandler) (interface{}, error) {
+		if *duration <= 0 || !deadline.set {
+			return nil, fmt.Errorf("%w; -duration and -deadline are required", errUsage)
+		}
+
+		if *postcode != "" {
+			return handler.GetGreenestWindowForPostcodeContext(ctx, *duration, deadline.value, *alternatives, *postcode)
+		}
+
+		return handler.GetGreenestWindowContext(ctx, *duration, deadline.value, *alternatives)
+	}
+}
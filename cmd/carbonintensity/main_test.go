@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexCrane/uk-grid-carbon-intensity"
+	"github.com/AlexCrane/uk-grid-carbon-intensity/carbonintensitytest"
+	"github.com/stretchr/testify/assert"
+)
+
+var testNow = time.Date(2018, 1, 20, 12, 10, 0, 0, time.UTC)
+
+func newTestServer() *carbonintensitytest.Server {
+	return carbonintensitytest.NewServer(carbonintensitytest.WithClock(func() time.Time { return testNow }))
+}
+
+func runCommand(server *carbonintensitytest.Server, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(append(args, "-base-url", server.URL), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCommandOutputFormats(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	code, stdout, stderr := runCommand(server, "between", "-from", "2018-01-20T10:00Z", "-to", "2018-01-20T11:00Z", "-format", "json")
+	assert.Equal(t, exitSuccess, code, stderr)
+
+	var intensities []*carbonintensity.Intensity
+	assert.Nil(t, json.Unmarshal([]byte(stdout), &intensities))
+	assert.Equal(t, 2, len(intensities))
+
+	code, stdout, stderr = runCommand(server, "between", "-from", "2018-01-20T10:00Z", "-to", "2018-01-20T11:00Z", "-format", "csv")
+	assert.Equal(t, exitSuccess, code, stderr)
+
+	records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(records))
+	assert.Equal(t, intensityHeader, records[0])
+	assert.Equal(t, "2018-01-20T10:00Z", records[1][0])
+
+	code, stdout, stderr = runCommand(server, "between", "-from", "2018-01-20T10:00Z", "-to", "2018-01-20T11:00Z")
+	assert.Equal(t, exitSuccess, code, stderr)
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	assert.Equal(t, 3, len(lines))
+	assert.Equal(t, []string{"from", "to", "forecast", "actual", "index"}, strings.Fields(lines[0]))
+}
+
+func TestCommandsMakeRequests(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	for _, test := range []struct {
+		args []string
+		path string
+	}{
+		{[]string{"current"}, "/intensity"},
+		{[]string{"at", "-time", "2018-01-20T10:00Z"}, "/intensity/2018-01-20T10:00Z"},
+		{[]string{"today"}, "/intensity/date"},
+		{[]string{"day", "-date", "2018-01-19"}, "/intensity/date/2018-01-19"},
+		{[]string{"day", "-date", "2018-01-19", "-period", "3"}, "/intensity/date/2018-01-19/3"},
+		{[]string{"forecast", "-from", "2018-01-20T10:00Z"}, "/intensity/2018-01-20T10:00Z/fw24h"},
+		{[]string{"forecast", "-from", "2018-01-20T10:00Z", "-hours", "48"}, "/intensity/2018-01-20T10:00Z/fw48h"},
+		{[]string{"prior", "-from", "2018-01-20T10:00Z"}, "/intensity/2018-01-20T10:00Z/pt24h"},
+		{[]string{"stats", "-from", "2018-01-19", "-to", "2018-01-20"}, "/intensity/stats/2018-01-19T00:00Z/2018-01-20T00:00Z"},
+		{[]string{"stats", "-from", "2018-01-19", "-to", "2018-01-20", "-block", "6h"}, "/intensity/stats/2018-01-19T00:00Z/2018-01-20T00:00Z/6"},
+		{[]string{"factors"}, "/intensity/factors"},
+		{[]string{"regional"}, "/regional"},
+		{[]string{"regional", "-region", "3"}, "/regional/regionid/3"},
+		{[]string{"regional", "-country", "scotland"}, "/regional/scotland"},
+		{[]string{"postcode", "-postcode", "RG10"}, "/regional/postcode/RG10"},
+		{[]string{"postcode", "-postcode", "RG10", "-from", "2018-01-20T10:00Z", "-forecast", "24"}, "/regional/intensity/2018-01-20T10:00Z/fw24h/postcode/RG10"},
+		{[]string{"generation"}, "/generation"},
+		{[]string{"generation", "-from", "2018-01-20T10:00Z", "-prior"}, "/generation/2018-01-20T10:00Z/pt24h"},
+	} {
+		server.ClearRequests()
+
+		code, stdout, stderr := runCommand(server, test.args...)
+		assert.Equal(t, exitSuccess, code, "%v: %s", test.args, stderr)
+		assert.NotEmpty(t, stdout, "%v", test.args)
+
+		requests := server.Requests()
+		if assert.Equal(t, 1, len(requests), "%v", test.args) {
+			assert.Equal(t, test.path, requests[0].Path, "%v", test.args)
+		}
+	}
+}
+
+func TestWindowCommand(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	deadline := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	code, stdout, stderr := runCommand(server, "window", "-duration", "2h", "-deadline", deadline, "-alternatives", "2", "-format", "csv")
+	assert.Equal(t, exitSuccess, code, stderr)
+
+	records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	assert.Nil(t, err)
+	if assert.Equal(t, 5, len(records)) {
+		assert.Equal(t, windowHeader, records[0])
+		assert.Equal(t, "best", records[1][0])
+		assert.Equal(t, "now", records[4][0])
+	}
+
+	code, _, _ = runCommand(server, "window", "-duration", "2h")
+	assert.Equal(t, exitUsage, code)
+}
+
+func TestCommandExitCodes(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	code, _, _ := runCommand(server, "unknown")
+	assert.Equal(t, exitUsage, code)
+
+	code, _, _ = runCommand(server, "current", "-format", "xml")
+	assert.Equal(t, exitUsage, code)
+
+	code, _, _ = runCommand(server, "between", "-from", "2018-01-20")
+	assert.Equal(t, exitUsage, code)
+
+	code, _, _ = runCommand(server, "between", "-from", "2018-01-20", "-to", "2018-01-19")
+	assert.Equal(t, exitUsage, code)
+
+	code, _, _ = runCommand(server, "regional", "-region", "99")
+	assert.Equal(t, exitUsage, code)
+
+	code, _, _ = runCommand(server, "postcode", "-postcode", "not a postcode")
+	assert.Equal(t, exitUsage, code)
+
+	server.AddFault(&carbonintensitytest.Fault{StatusCode: http.StatusBadRequest, Message: "Bad request", Count: 1})
+	code, _, stderr := runCommand(server, "current")
+	assert.Equal(t, exitAPIError, code)
+	assert.Contains(t, stderr, "Bad request")
+
+	server.AddFault(&carbonintensitytest.Fault{Body: "{", Count: 1})
+	code, _, _ = runCommand(server, "current")
+	assert.Equal(t, exitUnexpectedResponse, code)
+
+	server.AddFault(&carbonintensitytest.Fault{Delay: time.Second, Count: 1})
+	code, _, _ = runCommand(server, "current", "-timeout", "10ms")
+	assert.Equal(t, exitRequestFailure, code)
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2018, 1, 20, 12, 10, 0, 0, time.UTC)
+
+	for value, expected := range map[string]time.Time{
+		"now":                  now,
+		"-24h":                 now.Add(-24 * time.Hour),
+		"+90m":                 now.Add(90 * time.Minute),
+		"2018-01-19":           time.Date(2018, 1, 19, 0, 0, 0, 0, time.UTC),
+		"2018-01-19T10:30Z":    time.Date(2018, 1, 19, 10, 30, 0, 0, time.UTC),
+		"2018-01-19T10:30:00Z": time.Date(2018, 1, 19, 10, 30, 0, 0, time.UTC),
+	} {
+		parsed, err := parseTime(value, now)
+		assert.Nil(t, err, value)
+		assert.True(t, expected.Equal(parsed), "%s: %s", value, parsed)
+	}
+
+	_, err := parseTime("yesterday", now)
+	assert.NotNil(t, err)
+}
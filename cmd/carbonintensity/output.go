@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlexCrane/uk-grid-carbon-intensity"
+)
+
+// outputFormats are the values of -format, each writing the result of a command to w
+var outputFormats = map[string]func(w io.Writer, result interface{}) error{
+	"table": writeTable,
+	"json":  writeJSON,
+	"csv":   writeCSV,
+}
+
+func writeJSON(w io.Writer, result interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+func writeTable(w io.Writer, result interface{}) error {
+	header, rows, err := tabulate(result)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, result interface{}) error {
+	header, rows, err := tabulate(result)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(rows)
+	return cw.Error()
+}
+
+var (
+	intensityHeader  = []string{"from", "to", "forecast", "actual", "index"}
+	statisticsHeader = []string{"from", "to", "max", "average", "min", "index"}
+	regionalHeader   = []string{"regionID", "shortName", "dnoRegion", "postcode", "from", "to", "forecast", "index"}
+	windowHeader     = []string{"window", "start", "end", "total", "average"}
+	generationHeader = []string{"from", "to", "biomass", "coal", "imports", "gas", "nuclear", "other", "hydro", "solar", "wind", "storage"}
+)
+
+// tabulate returns the header and rows of a table of result, which is the result of one of the commands
+func tabulate(result interface{}) ([]string, [][]string, error) {
+	switch result := result.(type) {
+	case *carbonintensity.Intensity:
+		return intensityHeader, [][]string{intensityRow(result)}, nil
+	case []*carbonintensity.Intensity:
+		rows := make([][]string, 0, len(result))
+		for _, intensity := range result {
+			rows = append(rows, intensityRow(intensity))
+		}
+
+		return intensityHeader, rows, nil
+	case *carbonintensity.Statistics:
+		return statisticsHeader, [][]string{statisticsRow(result)}, nil
+	case []*carbonintensity.Statistics:
+		rows := make([][]string, 0, len(result))
+		for _, statistics := range result {
+			rows = append(rows, statisticsRow(statistics))
+		}
+
+		return statisticsHeader, rows, nil
+	case *carbonintensity.RegionalIntensity:
+		return regionalHeader, [][]string{regionalRow(result)}, nil
+	case []*carbonintensity.RegionalIntensity:
+		rows := make([][]string, 0, len(result))
+		for _, regional := range result {
+			rows = append(rows, regionalRow(regional))
+		}
+
+		return regionalHeader, rows, nil
+	case *carbonintensity.GenerationMix:
+		return generationHeader, [][]string{generationRow(result)}, nil
+	case []*carbonintensity.GenerationMix:
+		rows := make([][]string, 0, len(result))
+		for _, mix := range result {
+			rows = append(rows, generationRow(mix))
+		}
+
+		return generationHeader, rows, nil
+	case *carbonintensity.IntensityFactors:
+		return factorsTable(result)
+	case *carbonintensity.WindowSchedule:
+		rows := [][]string{windowRow("best", result.Best)}
+		for i, window := range result.Alternatives {
+			rows = append(rows, windowRow(fmt.Sprintf("alternative %d", i+1), window))
+		}
+
+		if result.Now != nil {
+			rows = append(rows, windowRow("now", result.Now))
+		}
+
+		return windowHeader, rows, nil
+	}
+
+	return nil, nil, fmt.Errorf("Can't tabulate %T", result)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04Z")
+}
+
+// formatValue formats an optional value, which is left empty if it isn't known
+func formatValue(value int, ok bool) string {
+	if !ok {
+		return ""
+	}
+
+	return strconv.Itoa(value)
+}
+
+func formatIndex(index carbonintensity.IntensityIndex) string {
+	if index == carbonintensity.IndexUnknown {
+		return ""
+	}
+
+	return index.String()
+}
+
+func intensityRow(intensity *carbonintensity.Intensity) []string {
+	return []string{
+		formatTime(intensity.From),
+		formatTime(intensity.To),
+		formatValue(intensity.ForecastValue()),
+		formatValue(intensity.ActualValue()),
+		formatIndex(intensity.Index),
+	}
+}
+
+func statisticsRow(statistics *carbonintensity.Statistics) []string {
+	return []string{
+		formatTime(statistics.From),
+		formatTime(statistics.To),
+		formatValue(statistics.MaxValue()),
+		formatValue(statistics.AverageValue()),
+		formatValue(statistics.MinValue()),
+		formatIndex(statistics.Index),
+	}
+}
+
+func regionalRow(regional *carbonintensity.RegionalIntensity) []string {
+	row := []string{strconv.Itoa(regional.RegionID), regional.ShortName, regional.DNORegion, regional.Postcode}
+	if regional.Intensity == nil {
+		return append(row, "", "", "", "")
+	}
+
+	return append(row,
+		formatTime(regional.Intensity.From),
+		formatTime(regional.Intensity.To),
+		formatValue(regional.Intensity.ForecastValue()),
+		formatIndex(regional.Intensity.Index),
+	)
+}
+
+func generationRow(mix *carbonintensity.GenerationMix) []string {
+	row := []string{formatTime(mix.From), formatTime(mix.To)}
+	for _, percentage := range []float64{
+		mix.Biomass, mix.Coal, mix.Imports, mix.Gas, mix.Nuclear, mix.Other, mix.Hydro, mix.Solar, mix.Wind, mix.Storage,
+	} {
+		row = append(row, strconv.FormatFloat(percentage, 'f', -1, 64))
+	}
+
+	return row
+}
+
+func windowRow(name string, window *carbonintensity.Window) []string {
+	return []string{
+		name,
+		formatTime(window.Start),
+		formatTime(window.End),
+		strconv.FormatFloat(window.Total, 'f', 1, 64),
+		strconv.FormatFloat(window.Average, 'f', 1, 64),
+	}
+}
+
+// factorsTable tabulates factors as one row per fuel type, named as they are by the API
+func factorsTable(factors *carbonintensity.IntensityFactors) ([]string, [][]string, error) {
+	encoded, err := json.Marshal(factors)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var byFuel map[string]int
+	if err := json.Unmarshal(encoded, &byFuel); err != nil {
+		return nil, nil, err
+	}
+
+	fuels := make([]string, 0, len(byFuel))
+	for fuel := range byFuel {
+		fuels = append(fuels, fuel)
+	}
+	sort.Strings(fuels)
+
+	rows := make([][]string, 0, len(fuels))
+	for _, fuel := range fuels {
+		rows = append(rows, []string{fuel, strconv.Itoa(byFuel[fuel])})
+	}
+
+	return []string{"fuel", "factor"}, rows, nil
+}
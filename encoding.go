@@ -0,0 +1,429 @@
+package carbonintensity
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The API wraps the result of every request in an object, with the result in "data", or the details of an error in "error"
+type apiResponse struct {
+	Data  json.RawMessage `json:"data"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// apiPeriod is the period of time an entry in the data of a response covers
+type apiPeriod struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// apiIntensity is the "intensity" member of an entry, which holds forecast and actual values for /intensity resources
+// and statistical values for /intensity/stats resources. Missing values are left nil.
+type apiIntensity struct {
+	Forecast *float64 `json:"forecast"`
+	Actual   *float64 `json:"actual"`
+	Max      *float64 `json:"max"`
+	Average  *float64 `json:"average"`
+	Min      *float64 `json:"min"`
+	Index    string   `json:"index"`
+}
+
+type apiIntensityEntry struct {
+	apiPeriod
+	Intensity *apiIntensity `json:"intensity"`
+}
+
+type apiFuel struct {
+	Fuel string  `json:"fuel"`
+	Perc float64 `json:"perc"`
+}
+
+type apiGenerationEntry struct {
+	apiPeriod
+	GenerationMix []apiFuel `json:"generationmix"`
+}
+
+// apiRegionalValues are the values given for a single region for a single period
+type apiRegionalValues struct {
+	Intensity     *apiIntensity `json:"intensity"`
+	GenerationMix []apiFuel     `json:"generationmix"`
+}
+
+type apiRegion struct {
+	RegionID  int    `json:"regionid"`
+	DNORegion string `json:"dnoregion"`
+	ShortName string `json:"shortname"`
+	Postcode  string `json:"postcode"`
+	apiRegionalValues
+}
+
+type apiRegionalPeriod struct {
+	apiPeriod
+	apiRegionalValues
+}
+
+// apiRegionalEntry is either a period with a list of regions, or a region with a list of periods, see regionalResponse
+type apiRegionalEntry struct {
+	apiPeriod
+	apiRegion
+	Regions []apiRegion         `json:"regions"`
+	Data    []apiRegionalPeriod `json:"data"`
+}
+
+// decodeAPIData unmarshals a response from the API, returning the contents of its "data" member.
+// If there is no "data" member the contents of the "error" member are returned as an error instead.
+func decodeAPIData(data []byte) (json.RawMessage, error) {
+	var response apiResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("%w; %s", ErrUnexpectedResponse, err)
+	}
+
+	if len(response.Data) == 0 || bytes.Equal(response.Data, []byte("null")) {
+		if response.Error == nil {
+			return nil, fmt.Errorf("%w; failed to unmarshal JSON; %s", ErrUnexpectedResponse, truncateBody(data))
+		}
+
+		return nil, newAPIError(response.Error.Code, response.Error.Message)
+	}
+
+	return response.Data, nil
+}
+
+// decodeAPIDataList decodes the "data" member of a response into entries, which must be a pointer to a slice.
+// Some resources return a single object as "data", rather than a list of one object, so both are accepted.
+func decodeAPIDataList(data []byte, entries interface{}) error {
+	decoded, err := decodeAPIData(data)
+	if err != nil {
+		return err
+	}
+
+	if trimmed := bytes.TrimSpace(decoded); len(trimmed) > 0 && trimmed[0] == '{' {
+		decoded = append(append([]byte{'['}, trimmed...), ']')
+	}
+
+	if err := json.Unmarshal(decoded, entries); err != nil {
+		return fmt.Errorf("%w; %s; %s", ErrUnexpectedResponse, err, truncateBody(data))
+	}
+
+	return nil
+}
+
+func (ap *apiPeriod) times() (time.Time, time.Time, error) {
+	fromTime, err := time.Parse(natGridTimeFormat, ap.From)
+	if err != nil {
+		return time.Time{}, time.Time{}, &EntryError{Field: "from", Value: ap.From, Err: err}
+	}
+
+	toTime, err := time.Parse(natGridTimeFormat, ap.To)
+	if err != nil {
+		return time.Time{}, time.Time{}, &EntryError{Field: "to", Value: ap.To, Err: err}
+	}
+
+	return fromTime, toTime, nil
+}
+
+// valueOrMissing returns val truncated to an int, or -1 if it is missing
+func valueOrMissing(val *float64) int {
+	if val == nil {
+		return -1
+	}
+
+	return int(*val)
+}
+
+func (ai *apiIntensity) index() (IntensityIndex, error) {
+	if ai == nil {
+		return IndexUnknown, &EntryError{Field: "intensity", Err: errors.New("missing")}
+	}
+
+	index, err := ParseIntensityIndex(ai.Index)
+	if err != nil {
+		return IndexUnknown, &EntryError{Field: "intensity.index", Value: ai.Index, Err: err}
+	}
+
+	return index, nil
+}
+
+// WithLenientDecoding makes the APIHandler skip entries of responses which can't be decoded (e.g. because of an invalid time),
+// rather than failing the whole request. When any entries are skipped the entries which could be decoded are returned
+// along with a *PartialResponseError, which holds a warning for each skipped entry.
+func WithLenientDecoding() Option {
+	return func(ah *APIHandler) {
+		ah.lenientDecoding = true
+	}
+}
+
+// entryDecoder is embedded in the response types to decode the entries of a response one at a time,
+// so that in lenient mode an invalid entry can be skipped without losing the rest
+type entryDecoder struct {
+	lenient  bool
+	warnings []*EntryError
+}
+
+// decodeEntries calls decodeEntry with each entry of the "data" member of a response
+func (ed *entryDecoder) decodeEntries(data []byte, decodeEntry func(index int, rawEntry json.RawMessage) error) error {
+	var rawEntries []json.RawMessage
+	if err := decodeAPIDataList(data, &rawEntries); err != nil {
+		return err
+	}
+
+	for index, rawEntry := range rawEntries {
+		if err := ed.check(index, "", decodeEntry(index, rawEntry)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// check returns err, as an *EntryError for the entry given by index, unless the entry can be skipped, in which case
+// it is recorded as a warning and nil is returned. fieldPrefix locates the value the error is about within the entry.
+func (ed *entryDecoder) check(index int, fieldPrefix string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var entryErr *EntryError
+	if errors.As(err, &entryErr) {
+		entryErr.Entry = index
+		if fieldPrefix != "" && entryErr.Field != "" {
+			entryErr.Field = fieldPrefix + "." + entryErr.Field
+		} else if fieldPrefix != "" {
+			entryErr.Field = fieldPrefix
+		}
+	} else {
+		entryErr = &EntryError{Entry: index, Field: fieldPrefix, Err: err}
+	}
+
+	if !ed.lenient {
+		return entryErr
+	}
+
+	ed.warnings = append(ed.warnings, entryErr)
+	return nil
+}
+
+// err returns a *PartialResponseError if any entries were skipped
+func (ed *entryDecoder) err() error {
+	if len(ed.warnings) == 0 {
+		return nil
+	}
+
+	return &PartialResponseError{Warnings: ed.warnings}
+}
+
+func newIntensity(period *apiPeriod, values *apiIntensity) (*Intensity, error) {
+	fromTime, toTime, err := period.times()
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := values.index()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Intensity{
+		From:     fromTime,
+		To:       toTime,
+		Forecast: valueOrMissing(values.Forecast),
+		Actual:   valueOrMissing(values.Actual),
+		Index:    index,
+	}, nil
+}
+
+func newStatistics(period *apiPeriod, values *apiIntensity) (*Statistics, error) {
+	fromTime, toTime, err := period.times()
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := values.index()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Statistics{
+		From:    fromTime,
+		To:      toTime,
+		Max:     valueOrMissing(values.Max),
+		Average: valueOrMissing(values.Average),
+		Min:     valueOrMissing(values.Min),
+		Index:   index,
+	}, nil
+}
+
+// optionalValue returns a pointer to val, or nil if val is -1 (i.e. missing)
+func optionalValue(val int) *int {
+	if val == -1 {
+		return nil
+	}
+
+	return &val
+}
+
+// optionalIndex returns a pointer to index, or nil if index is IndexUnknown
+func optionalIndex(index IntensityIndex) *IntensityIndex {
+	if index == IndexUnknown {
+		return nil
+	}
+
+	return &index
+}
+
+// intensityJSON is the JSON representation of Intensity
+type intensityJSON struct {
+	From     time.Time       `json:"from"`
+	To       time.Time       `json:"to"`
+	Forecast *int            `json:"forecast"`
+	Actual   *int            `json:"actual"`
+	Index    *IntensityIndex `json:"index"`
+}
+
+// MarshalJSON implements json.Marshaler
+//
+// Forecast and Actual are null, rather than -1, when they are missing, as is Index when it is IndexUnknown.
+func (ie Intensity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&intensityJSON{
+		From:     ie.From,
+		To:       ie.To,
+		Forecast: optionalValue(ie.Forecast),
+		Actual:   optionalValue(ie.Actual),
+		Index:    optionalIndex(ie.Index),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting the output of MarshalJSON
+func (ie *Intensity) UnmarshalJSON(data []byte) error {
+	var decoded intensityJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*ie = Intensity{
+		From:     decoded.From,
+		To:       decoded.To,
+		Forecast: intOrMissing(decoded.Forecast),
+		Actual:   intOrMissing(decoded.Actual),
+		Index:    indexOrUnknown(decoded.Index),
+	}
+
+	return nil
+}
+
+// statisticsJSON is the JSON representation of Statistics
+type statisticsJSON struct {
+	From    time.Time       `json:"from"`
+	To      time.Time       `json:"to"`
+	Max     *int            `json:"max"`
+	Average *int            `json:"average"`
+	Min     *int            `json:"min"`
+	Index   *IntensityIndex `json:"index"`
+}
+
+// MarshalJSON implements json.Marshaler
+//
+// Max, Average and Min are null, rather than -1, when they are missing, as is Index when it is IndexUnknown.
+func (se Statistics) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&statisticsJSON{
+		From:    se.From,
+		To:      se.To,
+		Max:     optionalValue(se.Max),
+		Average: optionalValue(se.Average),
+		Min:     optionalValue(se.Min),
+		Index:   optionalIndex(se.Index),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting the output of MarshalJSON
+func (se *Statistics) UnmarshalJSON(data []byte) error {
+	var decoded statisticsJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*se = Statistics{
+		From:    decoded.From,
+		To:      decoded.To,
+		Max:     intOrMissing(decoded.Max),
+		Average: intOrMissing(decoded.Average),
+		Min:     intOrMissing(decoded.Min),
+		Index:   indexOrUnknown(decoded.Index),
+	}
+
+	return nil
+}
+
+func intOrMissing(val *int) int {
+	if val == nil {
+		return -1
+	}
+
+	return *val
+}
+
+func indexOrUnknown(index *IntensityIndex) IntensityIndex {
+	if index == nil {
+		return IndexUnknown
+	}
+
+	return *index
+}
+
+// fields returns the fields of IntensityFactors keyed by the name of the fuel type used by the API
+func (ifs *IntensityFactors) fields() map[string]*int {
+	return map[string]*int{
+		"Biomass":              &ifs.Biomass,
+		"Coal":                 &ifs.Coal,
+		"Dutch Imports":        &ifs.DutchImports,
+		"French Imports":       &ifs.FrenchImports,
+		"Gas (Combined Cycle)": &ifs.GasCombinedCycle,
+		"Gas (Open Cycle)":     &ifs.GasOpenCycle,
+		"Hydro":                &ifs.Hydro,
+		"Irish Imports":        &ifs.IrishImports,
+		"Nuclear":              &ifs.Nuclear,
+		"Oil":                  &ifs.Oil,
+		"Other":                &ifs.Other,
+		"Pumped Storage":       &ifs.PumpedStorage,
+		"Solar":                &ifs.Solar,
+		"Wind":                 &ifs.Wind,
+	}
+}
+
+// MarshalJSON implements json.Marshaler, using the same names for the fuel types as the API (e.g. "Gas (Combined Cycle)")
+func (ifs IntensityFactors) MarshalJSON() ([]byte, error) {
+	values := make(map[string]int)
+	for name, field := range ifs.fields() {
+		values[name] = *field
+	}
+
+	return json.Marshal(values)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting both the output of MarshalJSON and the factors returned by the API.
+// It is an error for any of the fuel types to be missing.
+func (ifs *IntensityFactors) UnmarshalJSON(data []byte) error {
+	var values map[string]float64
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	var factors IntensityFactors
+	for name, field := range factors.fields() {
+		value, ok := values[name]
+		if !ok {
+			return fmt.Errorf("missing intensity factor %q", name)
+		}
+
+		*field = int(value)
+	}
+
+	*ifs = factors
+	return nil
+}
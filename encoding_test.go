@@ -0,0 +1,193 @@
+package carbonintensity
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntensityJSON(t *testing.T) {
+	from := time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC)
+
+	intensity := &Intensity{From: from, To: from.Add(30 * time.Minute), Forecast: 266, Actual: -1, Index: IndexModerate}
+
+	encoded, err := json.Marshal(intensity)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"from":"2018-01-20T12:00:00Z","to":"2018-01-20T12:30:00Z","forecast":266,"actual":null,"index":"moderate"}`, string(encoded))
+
+	decoded := &Intensity{}
+	assert.NoError(t, json.Unmarshal(encoded, decoded))
+	assert.Equal(t, intensity, decoded)
+
+	// Values as well as pointers are marshalled the same way
+	encoded, err = json.Marshal([]Intensity{{From: from, To: from, Forecast: -1, Actual: 100}})
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"from":"2018-01-20T12:00:00Z","to":"2018-01-20T12:00:00Z","forecast":null,"actual":100,"index":null}]`, string(encoded))
+}
+
+func TestStatisticsJSON(t *testing.T) {
+	from := time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC)
+
+	statistics := &Statistics{From: from, To: from.Add(24 * time.Hour), Max: 320, Average: 266, Min: -1, Index: IndexHigh}
+
+	encoded, err := json.Marshal(statistics)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"from":"2018-01-20T12:00:00Z","to":"2018-01-21T12:00:00Z","max":320,"average":266,"min":null,"index":"high"}`, string(encoded))
+
+	decoded := &Statistics{}
+	assert.NoError(t, json.Unmarshal(encoded, decoded))
+	assert.Equal(t, statistics, decoded)
+}
+
+func TestIntensityFactorsJSON(t *testing.T) {
+	factors := &IntensityFactors{Biomass: 120, Coal: 937, DutchImports: 474, FrenchImports: 53, GasCombinedCycle: 394, GasOpenCycle: 651,
+		IrishImports: 458, Oil: 935, Other: 300}
+
+	encoded, err := json.Marshal(factors)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Biomass":120,"Coal":937,"Dutch Imports":474,"French Imports":53,"Gas (Combined Cycle)":394,"Gas (Open Cycle)":651,`+
+		`"Hydro":0,"Irish Imports":458,"Nuclear":0,"Oil":935,"Other":300,"Pumped Storage":0,"Solar":0,"Wind":0}`, string(encoded))
+
+	decoded := &IntensityFactors{}
+	assert.NoError(t, json.Unmarshal(encoded, decoded))
+	assert.Equal(t, factors, decoded)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"Biomass":120}`), decoded))
+}
+
+func TestMalformedResponses(t *testing.T) {
+	responses := map[string]string{
+		"/intensity":                                           `{"data":{"from":"2018-01-20T12:00Z"}}`,
+		"/intensity/date":                                      `{"data":[{"from":5,"to":"2018-01-20T12:30Z","intensity":{"forecast":266,"actual":263,"index":"moderate"}}]}`,
+		"/intensity/factors":                                   `{"data":[{"Biomass":"lots"}]}`,
+		"/intensity/2018-01-20T12:00Z/pt24h":                   `{"data":[{"from":"2018-01-20T12:00Z","to":"2018-01-20T12:30Z","intensity":"moderate"}]}`,
+		"/intensity/2018-01-20T12:00Z/fw24h":                   `{"data":[{"from":"2018-01-20T12:00Z","to":"2018-01-20T12:30Z","intensity":{"forecast":266,"index":"awful"}}]}`,
+		"/intensity/2018-01-20T12:00Z/fw48h":                   `{"data":"nothing"}`,
+		"/intensity/stats/2018-01-20T12:00Z/2018-01-21T12:00Z": `{"data":[{"from":"2018-01-20T12:00Z","to":"2018-01-21T12:00Z"}]}`,
+		"/generation":                                          `{"data":{"from":"2018-01-20T12:00Z","to":"2018-01-20T12:30Z","generationmix":[{"fuel":"gas","perc":"43.6"}]}}`,
+		"/regional":                                            `{"data":[{"from":"2018-01-20T12:00Z","to":"2018-01-20T12:30Z","regions":[{"regionid":"one"}]}]}`,
+		"/regional/england":                                    `{"data":[{"regionid":15,"data":[{"from":"2018-01-20T12:00Z","to":"2018-01-20T12:30Z"}]}]}`,
+		"/regional/scotland":                                   `{"data":[{"regionid":16}]}`,
+		"/regional/wales":                                      `{"error":{"code":400}}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(responses[r.URL.Path]))
+	}))
+	defer server.Close()
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL))
+	from := time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC)
+
+	// Each of these should return an error, rather than panicking or returning partial results
+	_, err := handler.GetCurrentIntensity()
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse), "%v", err)
+	_, err = handler.GetTodaysIntensity()
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse), "%v", err)
+	_, err = handler.GetIntensityFactors()
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse), "%v", err)
+	_, err = handler.GetPrior24HourIntensity(from)
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse), "%v", err)
+	_, err = handler.GetNext24HourIntensity(from)
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse), "%v", err)
+	_, err = handler.GetNext48HourIntensity(from)
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse), "%v", err)
+	_, err = handler.GetStatistics(from, from.Add(24*time.Hour))
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse), "%v", err)
+	_, err = handler.GetCurrentGenerationMix()
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse), "%v", err)
+	_, err = handler.GetRegionalIntensity()
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse), "%v", err)
+	_, err = handler.GetEnglandIntensity()
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse), "%v", err)
+	_, err = handler.GetScotlandIntensity()
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse), "%v", err)
+	_, err = handler.GetWalesIntensity()
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse), "%v", err)
+}
+
+const testInvalidEntriesResponse = `{"data":[
+	{"from":"2018-01-20T12:00Z","to":"2018-01-20T12:30Z","intensity":{"forecast":266,"actual":263,"index":"moderate"}},
+	{"from":"2018-01-20T12:30Z","to":"2018-01-20 13:00","intensity":{"forecast":266,"actual":263,"index":"moderate"}},
+	{"from":"2018-01-20T13:00Z","to":"2018-01-20T13:30Z","intensity":{"forecast":266,"actual":263,"index":"awful"}},
+	{"from":"2018-01-20T13:30Z","to":"2018-01-20T14:00Z","intensity":{"forecast":266,"actual":263,"index":"moderate"}}]}`
+
+const testInvalidRegionalEntriesResponse = `{"data":[{"regionid":15,"dnoregion":"England","shortname":"England","data":[
+	{"from":"2018-01-20T12:00Z","to":"2018-01-20T12:30Z","intensity":{"forecast":266,"index":"moderate"}},
+	{"from":"yesterday","to":"2018-01-20T13:00Z","intensity":{"forecast":266,"index":"moderate"}}]}]}`
+
+func TestDecodeEntryErrors(t *testing.T) {
+	from := time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC)
+
+	handler, closeServer := newTestAPIHandler(t, map[string]string{
+		"/intensity/2018-01-20T12:00Z/2018-01-20T14:00Z":                        testInvalidEntriesResponse,
+		"/regional/intensity/2018-01-20T12:00Z/2018-01-20T13:00Z/postcode/RG10": testInvalidRegionalEntriesResponse,
+	})
+	defer closeServer()
+
+	// The first invalid entry fails the request, and is identified by the error
+	intensityArr, err := handler.GetIntensityBetween(from, from.Add(2*time.Hour))
+	assert.Nil(t, intensityArr)
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse))
+
+	var entryErr *EntryError
+	assert.True(t, errors.As(err, &entryErr))
+	assert.Equal(t, 1, entryErr.Entry)
+	assert.Equal(t, "to", entryErr.Field)
+	assert.Equal(t, "2018-01-20 13:00", entryErr.Value)
+	assert.Contains(t, err.Error(), `invalid to "2018-01-20 13:00" in entry 1`)
+
+	var parseErr *time.ParseError
+	assert.True(t, errors.As(err, &parseErr))
+
+	_, err = handler.GetIntensityBetweenForPostcode(from, from.Add(time.Hour), "RG10")
+	assert.True(t, errors.As(err, &entryErr))
+	assert.Equal(t, 0, entryErr.Entry)
+	assert.Equal(t, "data[1].from", entryErr.Field)
+	assert.Equal(t, "yesterday", entryErr.Value)
+}
+
+func TestLenientDecoding(t *testing.T) {
+	from := time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC)
+
+	handler, closeServer := newTestAPIHandler(t, map[string]string{
+		"/intensity/2018-01-20T12:00Z/2018-01-20T14:00Z":                        testInvalidEntriesResponse,
+		"/regional/intensity/2018-01-20T12:00Z/2018-01-20T13:00Z/postcode/RG10": testInvalidRegionalEntriesResponse,
+		"/intensity": testCurrentIntensityResponse,
+	})
+	defer closeServer()
+	WithLenientDecoding()(handler)
+
+	// Invalid entries are skipped, and reported as warnings along with the valid entries
+	intensityArr, err := handler.GetIntensityBetween(from, from.Add(2*time.Hour))
+	assert.Equal(t, 2, len(intensityArr))
+	assert.Equal(t, from, intensityArr[0].From)
+	assert.Equal(t, from.Add(90*time.Minute), intensityArr[1].From)
+
+	var partialErr *PartialResponseError
+	assert.True(t, errors.As(err, &partialErr))
+	assert.Equal(t, 2, len(partialErr.Warnings))
+	assert.Equal(t, 1, partialErr.Warnings[0].Entry)
+	assert.Equal(t, "to", partialErr.Warnings[0].Field)
+	assert.Equal(t, 2, partialErr.Warnings[1].Entry)
+	assert.Equal(t, "intensity.index", partialErr.Warnings[1].Field)
+	assert.True(t, errors.Is(partialErr.Warnings[1], ErrUnknownIndex))
+
+	// Only the invalid period of a regional entry is skipped
+	regionalArr, err := handler.GetIntensityBetweenForPostcode(from, from.Add(time.Hour), "RG10")
+	assert.Equal(t, 1, len(regionalArr))
+	assert.Equal(t, 15, regionalArr[0].RegionID)
+	assert.True(t, errors.As(err, &partialErr))
+	assert.Equal(t, 1, len(partialErr.Warnings))
+	assert.Equal(t, "data[1].from", partialErr.Warnings[0].Field)
+
+	// Valid responses don't have any warnings
+	_, err = handler.GetCurrentIntensity()
+	assert.NoError(t, err)
+}
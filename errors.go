@@ -0,0 +1,140 @@
+package carbonintensity
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors returned by APIHandler functions, these can be checked for using errors.Is
+//
+// Errors reported by the API are returned as an *APIError, which can be checked for using errors.As.
+// Errors making the request (e.g. network failures) are returned as they come from the http.Client, usually as a *url.Error.
+var (
+	// ErrInvalidRange is returned when the start of a time range is not strictly earlier than the end
+	ErrInvalidRange = errors.New("Invalid time range")
+	// ErrRangeTooLarge is returned when a time range is longer than the API allows
+	ErrRangeTooLarge = errors.New("Time range too large")
+	// ErrInvalidSettlementPeriod is returned when a settlement period is out of range
+	ErrInvalidSettlementPeriod = errors.New("Invalid settlementPeriod")
+	// ErrInvalidBlockSize is returned when a statistics block size is out of range
+	ErrInvalidBlockSize = errors.New("Invalid blocksize")
+	// ErrInvalidRegionID is returned when a region ID is out of range
+	ErrInvalidRegionID = errors.New("Invalid regionID")
+	// ErrUnexpectedResponse is returned when a response from the API can't be understood
+	ErrUnexpectedResponse = errors.New("Unexpected API response")
+)
+
+// The most of a response body included in errors
+const maxErrorBodyLength = 512
+
+// APIError is returned when the API responds with an error
+//
+// StatusCode is the HTTP status code of the error (e.g. 400). Code and Message are as reported by the API,
+// e.g. Code "400 Bad Request" and Message "Please enter a valid date in ISO8601 format".
+// If the API didn't report any details (e.g. an error page from a load balancer), Code and Message are taken from the HTTP status.
+//
+// URL and Body are the request URL and the (possibly truncated) response body, when the error came from an unsuccessful HTTP status.
+// RetryAfter is how long the response's Retry-After header asked us to wait before retrying, or 0 if it didn't.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	URL        string
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (ae *APIError) Error() string {
+	if ae.URL == "" {
+		return fmt.Sprintf("API error; Code: %s Message: %s", ae.Code, ae.Message)
+	}
+
+	return fmt.Sprintf("API error; Code: %s Message: %s URL: %s", ae.Code, ae.Message, ae.URL)
+}
+
+// ResponseError is returned when a successful response from the API can't be used, because it isn't JSON or is too large.
+// errors.Is(err, ErrUnexpectedResponse) is true for a *ResponseError.
+//
+// Body is the (possibly truncated) response body.
+type ResponseError struct {
+	StatusCode  int
+	ContentType string
+	URL         string
+	Body        string
+	Reason      string
+}
+
+func (re *ResponseError) Error() string {
+	return fmt.Sprintf("%s; %s; Status: %d Content-Type: %s URL: %s Body: %s", ErrUnexpectedResponse, re.Reason, re.StatusCode,
+		re.ContentType, re.URL, re.Body)
+}
+
+// Is allows errors.Is(err, ErrUnexpectedResponse) to match a *ResponseError
+func (re *ResponseError) Is(target error) bool {
+	return target == ErrUnexpectedResponse
+}
+
+// EntryError is returned when an entry in the data of a response from the API can't be decoded, e.g. because its "from" time is invalid.
+// errors.Is(err, ErrUnexpectedResponse) is true for an *EntryError.
+//
+// Entry is the index of the entry in the data of the response. Field is the member of the entry which is invalid (e.g. "from",
+// "intensity.index" or "data[2].to" for a period of a regional entry) and Value is its value. Field is empty if the entry as a whole is invalid.
+type EntryError struct {
+	Entry int
+	Field string
+	Value string
+	Err   error
+}
+
+func (ee *EntryError) Error() string {
+	if ee.Field == "" {
+		return fmt.Sprintf("%s; invalid entry %d; %s", ErrUnexpectedResponse, ee.Entry, ee.Err)
+	}
+
+	return fmt.Sprintf("%s; invalid %s %q in entry %d; %s", ErrUnexpectedResponse, ee.Field, ee.Value, ee.Entry, ee.Err)
+}
+
+// Unwrap returns the underlying error, e.g. the *time.ParseError for an invalid time
+func (ee *EntryError) Unwrap() error {
+	return ee.Err
+}
+
+// Is allows errors.Is(err, ErrUnexpectedResponse) to match an *EntryError
+func (ee *EntryError) Is(target error) bool {
+	return target == ErrUnexpectedResponse
+}
+
+// PartialResponseError is returned, along with the entries which could be decoded, when entries of a response were skipped
+// because they couldn't be decoded. This only happens when WithLenientDecoding is used, otherwise the *EntryError is returned instead.
+//
+// Warnings holds an *EntryError for each skipped entry.
+type PartialResponseError struct {
+	Warnings []*EntryError
+}
+
+func (pre *PartialResponseError) Error() string {
+	return fmt.Sprintf("Skipped %d invalid entries of API response; first was %s", len(pre.Warnings), pre.Warnings[0])
+}
+
+// truncateBody returns body as a string, truncated to maxErrorBodyLength bytes so that it can be included in an error
+func truncateBody(body []byte) string {
+	if len(body) <= maxErrorBodyLength {
+		return string(body)
+	}
+
+	return string(body[:maxErrorBodyLength]) + "...(truncated)"
+}
+
+// newAPIError returns an APIError for the code and message reported by the API.
+// The API reports codes such as "400 Bad Request", so the status code is taken from the start of code.
+func newAPIError(code string, message string) *APIError {
+	var statusCode int
+	fmt.Sscanf(code, "%d", &statusCode)
+
+	return &APIError{
+		StatusCode: statusCode,
+		Code:       code,
+		Message:    message,
+	}
+}
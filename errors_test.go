@@ -0,0 +1,175 @@
+package carbonintensity
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/intensity/date/2018-01-20":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":"400 Bad Request","message":"Please enter a valid date in ISO8601 format"}}`))
+		case "/intensity/date":
+			// Some errors are reported by the API with a successful status code
+			w.Write([]byte(`{"error":{"code":"400 Bad Request","message":"Invalid request"}}`))
+		case "/intensity":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/intensity/factors":
+			w.Write([]byte(`{"data":[`))
+		case "/intensity/stats/2018-01-20T00:00Z/2018-01-21T00:00Z":
+			w.Write([]byte(`{"data":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL))
+	day := time.Date(2018, 1, 20, 0, 0, 0, 0, time.UTC)
+
+	var apiErr *APIError
+
+	_, err := handler.GetIntensityForDay(day)
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "400 Bad Request", apiErr.Code)
+	assert.Equal(t, "Please enter a valid date in ISO8601 format", apiErr.Message)
+
+	_, err = handler.GetTodaysIntensity()
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "Invalid request", apiErr.Message)
+
+	_, err = handler.GetCurrentIntensity()
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+
+	_, err = handler.GetIntensityFactors()
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse))
+
+	_, err = handler.GetStatistics(day, day.Add(24*time.Hour))
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse))
+
+	// Errors making the request should not be APIErrors
+	unreachableHandler := NewCarbonIntensityAPIHandler(WithBaseURL("http://127.0.0.1:0"))
+	_, err = unreachableHandler.GetCurrentIntensity()
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &apiErr))
+
+	var urlErr *url.Error
+	assert.True(t, errors.As(err, &urlErr))
+}
+
+func TestValidationErrors(t *testing.T) {
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL("http://127.0.0.1:0"))
+	now := time.Now()
+
+	_, err := handler.GetIntensityBetween(now, now)
+	assert.True(t, errors.Is(err, ErrInvalidRange))
+
+	_, err = handler.GetStatistics(now.Add(time.Hour), now)
+	assert.True(t, errors.Is(err, ErrInvalidRange))
+
+	_, err = handler.GetIntensityBetween(now.Add(-31*24*time.Hour), now)
+	assert.True(t, errors.Is(err, ErrRangeTooLarge))
+
+	_, err = handler.GetStatisticsInBlocks(now.Add(-31*24*time.Hour), now, time.Hour)
+	assert.True(t, errors.Is(err, ErrRangeTooLarge))
+
+	_, err = handler.GetIntensityBetweenForPostcode(now.Add(-15*24*time.Hour), now, "RG10")
+	assert.True(t, errors.Is(err, ErrRangeTooLarge))
+
+	_, err = handler.GetStatisticsInBlocks(now.Add(-time.Hour), now, 25*time.Hour)
+	assert.True(t, errors.Is(err, ErrInvalidBlockSize))
+
+	_, err = handler.GetIntensityForDayAndSettlementPeriod(now, 51)
+	assert.True(t, errors.Is(err, ErrInvalidSettlementPeriod))
+
+	_, err = handler.GetIntensityForDayAndSettlementPeriod(time.Date(2018, 3, 25, 0, 0, 0, 0, time.UTC), 47)
+	assert.True(t, errors.Is(err, ErrInvalidSettlementPeriod))
+
+	_, err = handler.GetIntensityForRegion(0)
+	assert.True(t, errors.Is(err, ErrInvalidRegionID))
+}
+
+func TestResponseValidation(t *testing.T) {
+	htmlPage := "<html><body>" + strings.Repeat("Bad Gateway ", 100) + "</body></html>"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/intensity":
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(htmlPage))
+		case "/intensity/date":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(htmlPage))
+		case "/intensity/factors":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"data":[`))
+			chunk := []byte(strings.Repeat(" ", 1024*1024))
+			for written := 0; written <= maxResponseBytes; written += len(chunk) {
+				w.Write(chunk)
+			}
+		}
+	}))
+	defer server.Close()
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL))
+
+	// Unsuccessful status without any error details from the API
+	var apiErr *APIError
+	_, err := handler.GetCurrentIntensity()
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	assert.Equal(t, "502 Bad Gateway", apiErr.Code)
+	assert.Equal(t, server.URL+"/intensity", apiErr.URL)
+	assert.True(t, strings.HasPrefix(apiErr.Body, "<html>"))
+	assert.True(t, strings.HasSuffix(apiErr.Body, "...(truncated)"))
+	assert.Equal(t, maxErrorBodyLength+len("...(truncated)"), len(apiErr.Body))
+	assert.Contains(t, err.Error(), apiErr.URL)
+
+	// Successful status, but not JSON
+	var responseErr *ResponseError
+	_, err = handler.GetTodaysIntensity()
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse))
+	assert.True(t, errors.As(err, &responseErr))
+	assert.Equal(t, http.StatusOK, responseErr.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", responseErr.ContentType)
+	assert.Equal(t, server.URL+"/intensity/date", responseErr.URL)
+	assert.True(t, len(responseErr.Body) < len(htmlPage))
+
+	// Successful status, but too large
+	_, err = handler.GetIntensityFactors()
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse))
+	assert.True(t, errors.As(err, &responseErr))
+	assert.True(t, len(err.Error()) < 2*maxErrorBodyLength)
+}
+
+func TestNullIntensityFactors(t *testing.T) {
+	handler, closeServer := newTestAPIHandler(t, map[string]string{
+		"/intensity/factors": `{"data":[null]}`,
+	})
+	defer closeServer()
+
+	factors, err := handler.GetIntensityFactors()
+	assert.Nil(t, factors)
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse))
+
+	var responseErr *ResponseError
+	if assert.True(t, errors.As(err, &responseErr)) {
+		assert.Equal(t, "entry is null", responseErr.Reason)
+		assert.Equal(t, `{"data":[null]}`, responseErr.Body)
+	}
+}
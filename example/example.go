@@ -47,11 +47,11 @@ func main() {
 			fmt.Printf("%c", forecastChar)
 		}
 		fmt.Print("\n")
-		if intensity.Actual != -1 {
+		if actual, ok := intensity.ActualValue(); ok {
 			for i := 0; i < timeColumnLen; i++ {
 				fmt.Printf("%c", ' ')
 			}
-			for i := 0; i < intensity.Actual; i += charRepSize {
+			for i := 0; i < actual; i += charRepSize {
 				fmt.Printf("%c", actualChar)
 			}
 			fmt.Print("\n")
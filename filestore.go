@@ -0,0 +1,323 @@
+package carbonintensity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// The kinds of entry a FileStore holds, which prefix the names of its files
+const (
+	intensityKind  = "intensity"
+	statisticsKind = "statistics"
+	generationKind = "generation"
+)
+
+// FileStore is a Store which keeps entries in files in a directory, as JSON lines (one JSON encoded entry per line)
+//
+// There is one file for each kind of entry (intensity, statistics and generation) for each month, named e.g.
+// intensity-2018-01.jsonl, holding the entries whose From is in that month (in UTC). Files are only appended to, with later
+// lines replacing earlier ones for the same entry, so they are never left inconsistent; an incomplete last line (e.g. from a
+// crash while writing) is ignored. Replaced lines are only removed by Compact.
+//
+// A FileStore is safe for concurrent use, but only one FileStore should use a directory at once.
+type FileStore struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+// NewFileStore returns a FileStore keeping its files in dir, which is created if it doesn't exist
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+func (fs *FileStore) path(kind string, year int, month time.Month) string {
+	return filepath.Join(fs.dir, fmt.Sprintf("%s-%04d-%02d.jsonl", kind, year, month))
+}
+
+// appendEntries appends a line for each of entries to the file for kind for the month of its start, given by from
+func (fs *FileStore) appendEntries(kind string, count int, from func(index int) time.Time, entry func(index int) interface{}) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	lines := make(map[string]*bytes.Buffer)
+	var paths []string
+	for index := 0; index < count; index++ {
+		year, month, _ := from(index).UTC().Date()
+		path := fs.path(kind, year, month)
+
+		encoded, err := json.Marshal(entry(index))
+		if err != nil {
+			return err
+		}
+
+		if lines[path] == nil {
+			lines[path] = &bytes.Buffer{}
+			paths = append(paths, path)
+		}
+
+		lines[path].Write(encoded)
+		lines[path].WriteByte('\n')
+	}
+
+	for _, path := range paths {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+
+		err = truncateIncompleteLine(file)
+		if err == nil {
+			_, err = file.Write(lines[path].Bytes())
+		}
+
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// truncateIncompleteLine removes a partly written last line from file, so that it isn't joined to the next line appended
+func truncateIncompleteLine(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil || last[0] == '\n' {
+		return err
+	}
+
+	contents := make([]byte, info.Size())
+	if _, err := file.ReadAt(contents, 0); err != nil {
+		return err
+	}
+
+	return file.Truncate(int64(bytes.LastIndexByte(contents, '\n') + 1))
+}
+
+// readLines calls decode for each complete line of the files for kind for the months from from to to
+func (fs *FileStore) readLines(kind string, from time.Time, to time.Time, decode func(line []byte) error) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	year, month, _ := from.UTC().Date()
+	for month := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC); month.Before(to); month = month.AddDate(0, 1, 0) {
+		path := fs.path(kind, month.Year(), month.Month())
+
+		contents, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		lines := bytes.Split(contents, []byte{'\n'})
+		// The last line is empty if complete, otherwise it was only partly written
+		for number, line := range lines[:len(lines)-1] {
+			if err := decode(line); err != nil {
+				return fmt.Errorf("Invalid entry in %s line %d; %s", path, number+1, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// PutIntensity implements Store
+func (fs *FileStore) PutIntensity(entries []*Intensity) error {
+	return fs.appendEntries(intensityKind, len(entries),
+		func(index int) time.Time { return entries[index].From },
+		func(index int) interface{} { return entries[index] })
+}
+
+// GetIntensity implements Store
+func (fs *FileStore) GetIntensity(from time.Time, to time.Time) ([]*Intensity, error) {
+	stored := make(map[storeKey]*Intensity)
+	err := fs.readLines(intensityKind, from, to, func(line []byte) error {
+		entry := &Intensity{}
+		if err := json.Unmarshal(line, entry); err != nil {
+			return err
+		}
+
+		stored[newStoreKey(entry.From, entry.To)] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Intensity
+	for key, entry := range stored {
+		if !key.from.Before(from) && key.from.Before(to) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sortIntensity(entries)
+	return entries, nil
+}
+
+// PutStatistics implements Store
+func (fs *FileStore) PutStatistics(entries []*Statistics) error {
+	return fs.appendEntries(statisticsKind, len(entries),
+		func(index int) time.Time { return entries[index].From },
+		func(index int) interface{} { return entries[index] })
+}
+
+// GetStatistics implements Store
+func (fs *FileStore) GetStatistics(from time.Time, to time.Time) ([]*Statistics, error) {
+	stored := make(map[storeKey]*Statistics)
+	err := fs.readLines(statisticsKind, from, to, func(line []byte) error {
+		entry := &Statistics{}
+		if err := json.Unmarshal(line, entry); err != nil {
+			return err
+		}
+
+		stored[newStoreKey(entry.From, entry.To)] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Statistics
+	for key, entry := range stored {
+		if !key.from.Before(from) && !key.to.After(to) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sortStatistics(entries)
+	return entries, nil
+}
+
+// PutGenerationMix implements Store
+func (fs *FileStore) PutGenerationMix(entries []*GenerationMix) error {
+	return fs.appendEntries(generationKind, len(entries),
+		func(index int) time.Time { return entries[index].From },
+		func(index int) interface{} { return entries[index] })
+}
+
+// GetGenerationMix implements Store
+func (fs *FileStore) GetGenerationMix(from time.Time, to time.Time) ([]*GenerationMix, error) {
+	stored := make(map[storeKey]*GenerationMix)
+	err := fs.readLines(generationKind, from, to, func(line []byte) error {
+		entry := &GenerationMix{}
+		if err := json.Unmarshal(line, entry); err != nil {
+			return err
+		}
+
+		stored[newStoreKey(entry.From, entry.To)] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*GenerationMix
+	for key, entry := range stored {
+		if !key.from.Before(from) && key.from.Before(to) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sortGenerationMix(entries)
+	return entries, nil
+}
+
+// Compact rewrites each file of the FileStore without the lines which have been replaced by later ones
+func (fs *FileStore) Compact() error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(fs.dir, "*.jsonl"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := compactFile(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compactFile rewrites the file at path with only the last line for each entry, in the order those lines were written
+func compactFile(path string) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := bytes.Split(contents, []byte{'\n'})
+	lines = lines[:len(lines)-1]
+
+	keys := make([]storeKey, len(lines))
+	lastLine := make(map[storeKey]int)
+	for number, line := range lines {
+		var period struct {
+			From time.Time `json:"from"`
+			To   time.Time `json:"to"`
+		}
+		if err := json.Unmarshal(line, &period); err != nil {
+			return fmt.Errorf("Invalid entry in %s line %d; %s", path, number+1, err)
+		}
+
+		keys[number] = newStoreKey(period.From, period.To)
+		lastLine[keys[number]] = number
+	}
+
+	var compacted bytes.Buffer
+	for number, line := range lines {
+		if lastLine[keys[number]] == number {
+			compacted.Write(line)
+			compacted.WriteByte('\n')
+		}
+	}
+
+	// Write to a temporary file first, so that the file is never left partly written
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), ".compacting-")
+	if err != nil {
+		return err
+	}
+
+	_, err = tempFile.Write(compacted.Bytes())
+	if err == nil {
+		err = tempFile.Chmod(0644)
+	}
+
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tempFile.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(tempFile.Name())
+	}
+
+	return err
+}
@@ -0,0 +1,258 @@
+package carbonintensity
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// FixtureModeEnvVar is the environment variable read by WithFixturesFromEnv, its value is parsed by ParseFixtureMode
+const FixtureModeEnvVar = "CARBONINTENSITY_FIXTURES"
+
+// FixtureMode is how a FixtureTransport uses its fixtures
+type FixtureMode int
+
+// The valid values of FixtureMode
+const (
+	// FixturesLive makes every request of the API, without using fixtures at all
+	FixturesLive FixtureMode = iota
+	// FixturesRecord makes every request of the API, saving each response as a fixture
+	FixturesRecord
+	// FixturesReplay serves responses from fixtures, making requests of the API for any which haven't been recorded
+	FixturesReplay
+	// FixturesReplayStrict serves responses from fixtures, failing requests which haven't been recorded with ErrFixtureNotFound
+	FixturesReplayStrict
+)
+
+// ErrFixtureNotFound is returned for requests which haven't been recorded when replaying fixtures with FixturesReplayStrict
+var ErrFixtureNotFound = errors.New("No fixture recorded for request")
+
+var fixtureModeNames = map[FixtureMode]string{
+	FixturesLive:         "live",
+	FixturesRecord:       "record",
+	FixturesReplay:       "replay",
+	FixturesReplayStrict: "strict",
+}
+
+// ParseFixtureMode returns the FixtureMode named by s; one of "live", "record", "replay" or "strict".
+// An empty s is FixturesLive.
+func ParseFixtureMode(s string) (FixtureMode, error) {
+	if s == "" {
+		return FixturesLive, nil
+	}
+
+	for mode, name := range fixtureModeNames {
+		if name == s {
+			return mode, nil
+		}
+	}
+
+	return FixturesLive, fmt.Errorf("Invalid fixture mode %q; must be one of live, record, replay or strict", s)
+}
+
+func (fm FixtureMode) String() string {
+	if name, ok := fixtureModeNames[fm]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("FixtureMode(%d)", int(fm))
+}
+
+// fixture is a recorded request and response, as saved to disk
+type fixture struct {
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	StatusCode  int    `json:"statusCode"`
+	ContentType string `json:"contentType,omitempty"`
+	RetryAfter  string `json:"retryAfter,omitempty"`
+	Body        string `json:"body"`
+}
+
+// FixtureTransport is an http.RoundTripper which records responses from the API to golden files on disk, and replays them
+//
+// There is one file in Dir for each request, named after the path and query of its URL. The scheme and host aren't part of
+// the name, so fixtures recorded from the API can be replayed whatever the base URL of the APIHandler.
+// Transport is used to make requests of the API, http.DefaultTransport is used if it is nil.
+type FixtureTransport struct {
+	Dir       string
+	Mode      FixtureMode
+	Transport http.RoundTripper
+
+	// err is returned for every request, when the transport couldn't be set up (see WithFixturesFromEnv)
+	err error
+}
+
+var unsafeFixtureChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fixturePath returns the path of the file for the fixture of req. As the name is made safe for any filesystem, a hash of the
+// full URL is included so that different URLs can't share a file.
+func (ft *FixtureTransport) fixturePath(req *http.Request) string {
+	key := req.Method + " " + req.URL.RequestURI()
+	hash := sha256.Sum256([]byte(key))
+
+	name := strings.Trim(unsafeFixtureChars.ReplaceAllString(req.URL.RequestURI(), "_"), "_")
+	if len(name) > 100 {
+		name = name[:100]
+	}
+
+	return filepath.Join(ft.Dir, fmt.Sprintf("%s-%s.json", name, hex.EncodeToString(hash[:4])))
+}
+
+func (ft *FixtureTransport) transport() http.RoundTripper {
+	if ft.Transport == nil {
+		return http.DefaultTransport
+	}
+
+	return ft.Transport
+}
+
+// RoundTrip implements http.RoundTripper
+func (ft *FixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if ft.err != nil {
+		return nil, ft.err
+	}
+
+	switch ft.Mode {
+	case FixturesLive:
+		return ft.transport().RoundTrip(req)
+	case FixturesRecord:
+		return ft.record(req)
+	case FixturesReplay, FixturesReplayStrict:
+		resp, err := ft.replay(req)
+		if errors.Is(err, ErrFixtureNotFound) && ft.Mode == FixturesReplay {
+			return ft.transport().RoundTrip(req)
+		}
+
+		return resp, err
+	}
+
+	return nil, fmt.Errorf("Invalid fixture mode %s", ft.Mode)
+}
+
+// record makes req of the API, saving the response as a fixture before returning it
+func (ft *FixtureTransport) record(req *http.Request) (*http.Response, error) {
+	resp, err := ft.transport().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded, err := json.MarshalIndent(&fixture{
+		Method:      req.Method,
+		URL:         req.URL.RequestURI(),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		RetryAfter:  resp.Header.Get("Retry-After"),
+		Body:        string(body),
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(ft.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// Write to a temporary file first, so that concurrent requests for the same URL can't leave a partly written fixture
+	path := ft.fixturePath(req)
+	tempFile, err := ioutil.TempFile(ft.Dir, ".recording-")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tempFile.Write(append(recorded, '\n'))
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tempFile.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return nil, err
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// replay returns the response recorded for req, or an error wrapping ErrFixtureNotFound if there isn't one
+func (ft *FixtureTransport) replay(req *http.Request) (*http.Response, error) {
+	path := ft.fixturePath(req)
+
+	recorded, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w; %s %s (expected %s)", ErrFixtureNotFound, req.Method, req.URL.RequestURI(), path)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var replayed fixture
+	if err := json.Unmarshal(recorded, &replayed); err != nil {
+		return nil, fmt.Errorf("Invalid fixture %s; %s", path, err)
+	}
+
+	header := make(http.Header)
+	if replayed.ContentType != "" {
+		header.Set("Content-Type", replayed.ContentType)
+	}
+
+	if replayed.RetryAfter != "" {
+		header.Set("Retry-After", replayed.RetryAfter)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", replayed.StatusCode, http.StatusText(replayed.StatusCode)),
+		StatusCode:    replayed.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(replayed.Body)),
+		ContentLength: int64(len(replayed.Body)),
+		Request:       req,
+	}, nil
+}
+
+// WithFixtures makes the APIHandler record responses to, or replay responses from, fixtures in dir as given by mode,
+// see FixtureTransport
+//
+// Requests of the API are made with the transport of the http.Client in use, which is copied rather than modified.
+func WithFixtures(dir string, mode FixtureMode) Option {
+	return func(ah *APIHandler) {
+		client := *ah.client
+		client.Transport = &FixtureTransport{Dir: dir, Mode: mode, Transport: client.Transport}
+		ah.client = &client
+	}
+}
+
+// WithFixturesFromEnv is the same as WithFixtures, but the mode is given by the FixtureModeEnvVar environment variable.
+// This allows test suites to be switched between live requests, recording and replaying without any changes.
+//
+// If the environment variable isn't set requests are made of the API as normal. If it is invalid every request fails.
+func WithFixturesFromEnv(dir string) Option {
+	return func(ah *APIHandler) {
+		mode, err := ParseFixtureMode(os.Getenv(FixtureModeEnvVar))
+
+		WithFixtures(dir, mode)(ah)
+		ah.client.Transport.(*FixtureTransport).err = err
+	}
+}
@@ -0,0 +1,110 @@
+package carbonintensity
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixtures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fixtures")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/intensity":
+			w.Write([]byte(testCurrentIntensityResponse))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":"400 Bad Request","message":"Please enter a valid date in ISO8601 format"}}`))
+		}
+	}))
+	defer server.Close()
+
+	day := time.Date(2018, 1, 20, 0, 0, 0, 0, time.UTC)
+
+	// Recording makes the requests, and saves both successful and unsuccessful responses
+	recorder := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithFixtures(dir, FixturesRecord))
+
+	recorded, err := recorder.GetCurrentIntensity()
+	assert.NoError(t, err)
+
+	_, err = recorder.GetIntensityForDay(day)
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(files))
+
+	// Replaying doesn't need the server at all, even though the base URL is different
+	replayer := NewCarbonIntensityAPIHandler(WithBaseURL("http://127.0.0.1:0"), WithFixtures(dir, FixturesReplayStrict))
+
+	replayed, err := replayer.GetCurrentIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, recorded, replayed)
+
+	_, err = replayer.GetIntensityForDay(day)
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "Please enter a valid date in ISO8601 format", apiErr.Message)
+
+	// Strict replaying fails requests which weren't recorded
+	_, err = replayer.GetTodaysIntensity()
+	assert.True(t, errors.Is(err, ErrFixtureNotFound))
+
+	// Otherwise they are made of the API
+	replayer = NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithFixtures(dir, FixturesReplay))
+
+	_, err = replayer.GetCurrentIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	_, err = replayer.GetTodaysIntensity()
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestFixturesFromEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fixtures")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	handler, closeServer := newTestAPIHandler(t, map[string]string{"/intensity": testCurrentIntensityResponse})
+	defer closeServer()
+
+	// Unset is live
+	t.Setenv(FixtureModeEnvVar, "")
+	WithFixturesFromEnv(dir)(handler)
+	_, err = handler.GetCurrentIntensity()
+	assert.NoError(t, err)
+
+	t.Setenv(FixtureModeEnvVar, "strict")
+	WithFixturesFromEnv(dir)(handler)
+	_, err = handler.GetCurrentIntensity()
+	assert.True(t, errors.Is(err, ErrFixtureNotFound))
+
+	t.Setenv(FixtureModeEnvVar, "sometimes")
+	WithFixturesFromEnv(dir)(handler)
+	_, err = handler.GetCurrentIntensity()
+	assert.Error(t, err)
+
+	for _, name := range []string{"live", "record", "replay", "strict"} {
+		mode, err := ParseFixtureMode(name)
+		assert.NoError(t, err)
+		assert.Equal(t, name, mode.String())
+	}
+}
@@ -0,0 +1,303 @@
+package carbonintensity
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// How long after the end of a settlement period UpdateActuals keeps fetching its actual intensity, after which the API is taken
+// never to publish one
+const actualsGiveUpTime = 24 * time.Hour
+
+// ForecastVintage is the forecast for upcoming settlement periods, as it was when fetched at Fetched
+type ForecastVintage struct {
+	Fetched  time.Time
+	Forecast []*Intensity
+}
+
+// ForecastRevision is the forecast for a settlement period in a single ForecastVintage
+type ForecastRevision struct {
+	Fetched  time.Time
+	Forecast int
+	Index    IntensityIndex
+}
+
+// ForecastAccuracy gives how accurate forecasts were, for forecasts made between LeadTime and LeadTime plus the bucket size
+// (see ForecastTracker.Accuracy) before the start of the settlement period they were for
+//
+// MAE is the mean absolute error and RMSE the root mean square error, in gCO2/KWh. Bias is the mean of the forecast less the
+// actual, so is positive when the forecast was too high. IndexHitRate is the fraction (from 0 to 1) of forecasts whose Index
+// was that given by ClassifyIntensity for the actual intensity. Count is how many forecasts these are calculated from.
+type ForecastAccuracy struct {
+	LeadTime     time.Duration
+	Count        int
+	MAE          float64
+	RMSE         float64
+	Bias         float64
+	IndexHitRate float64
+}
+
+func (fa *ForecastAccuracy) String() string {
+	return fmt.Sprintf("%s {count: %d, MAE: %.1f, RMSE: %.1f, bias: %.1f, index hit rate: %.2f}", fa.LeadTime, fa.Count, fa.MAE,
+		fa.RMSE, fa.Bias, fa.IndexHitRate)
+}
+
+// ForecastTracker keeps every vintage of the forecast it is given or fetches, and the actual intensities once published,
+// to track how forecasts are revised and how accurate they are depending on how far ahead they were made.
+//
+// Vintages are fetched with Snapshot and actuals with UpdateActuals, or both periodically with Run. Vintages and actuals
+// fetched elsewhere can be added with AddVintage and AddActuals. Nothing is discarded unless Prune is called.
+//
+// A ForecastTracker is safe for concurrent use.
+type ForecastTracker struct {
+	handler *APIHandler
+
+	mutex    sync.Mutex
+	vintages []*ForecastVintage
+	// The actual intensity of each period which has one, by From in UTC
+	actuals map[time.Time]*Intensity
+	// The periods whose actual intensity UpdateActuals has fetched, by From in UTC
+	attempted map[time.Time]bool
+	now       func() time.Time
+}
+
+// NewForecastTracker returns a ForecastTracker which fetches forecasts and actuals using handler
+func NewForecastTracker(handler *APIHandler) *ForecastTracker {
+	return &ForecastTracker{
+		handler:   handler,
+		actuals:   make(map[time.Time]*Intensity),
+		attempted: make(map[time.Time]bool),
+		now:       time.Now,
+	}
+}
+
+// Snapshot fetches the forecast from GetNext48HourIntensity, adding it as a vintage fetched at the current time
+func (ft *ForecastTracker) Snapshot(ctx context.Context) (*ForecastVintage, error) {
+	fetched := ft.now()
+
+	forecast, err := ft.handler.GetNext48HourIntensityContext(ctx, fetched)
+	if err != nil {
+		return nil, err
+	}
+
+	vintage := &ForecastVintage{Fetched: fetched, Forecast: forecast}
+	ft.AddVintage(vintage)
+	return vintage, nil
+}
+
+// AddVintage adds vintage to those tracked. Vintages may be added in any order.
+func (ft *ForecastTracker) AddVintage(vintage *ForecastVintage) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	index := sort.Search(len(ft.vintages), func(i int) bool { return ft.vintages[i].Fetched.After(vintage.Fetched) })
+	ft.vintages = append(ft.vintages, nil)
+	copy(ft.vintages[index+1:], ft.vintages[index:])
+	ft.vintages[index] = vintage
+}
+
+// AddActuals records the Actual value of each of entries which has one
+func (ft *ForecastTracker) AddActuals(entries []*Intensity) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	for _, entry := range entries {
+		if entry.HasActual() {
+			ft.actuals[entry.From.UTC()] = entry
+		}
+	}
+}
+
+// UpdateActuals fetches the actual intensity of the settlement periods in the tracked vintages which have ended but don't have
+// one yet, with GetIntensityBetweenChunked. Each run of consecutive periods without one is fetched separately, so that a
+// period the API never publishes an actual for doesn't cause everything after it to be fetched again. Periods the API has no
+// actual for yet are left to be fetched by a later call, until 24 hours after they ended; after that the API is taken never
+// to publish one, and they are only fetched if they haven't been before.
+func (ft *ForecastTracker) UpdateActuals(ctx context.Context) error {
+	now := ft.now()
+
+	ft.mutex.Lock()
+	missing := make(map[time.Time]bool)
+	for _, vintage := range ft.vintages {
+		for _, entry := range vintage.Forecast {
+			from := entry.From.UTC()
+			if _, ok := ft.actuals[from]; ok || entry.To.After(now) {
+				continue
+			}
+
+			if !ft.attempted[from] || entry.To.Add(actualsGiveUpTime).After(now) {
+				missing[from] = true
+			}
+		}
+	}
+	ft.mutex.Unlock()
+
+	starts := make([]time.Time, 0, len(missing))
+	for start := range missing {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	for _, run := range missingRuns(starts, func(time.Time) bool { return false }) {
+		entries, err := ft.handler.GetIntensityBetweenChunkedContext(ctx, run.from, run.to)
+		if err != nil && !isChunkWarning(err) {
+			return err
+		}
+
+		ft.AddActuals(entries)
+
+		ft.mutex.Lock()
+		for from := run.from; from.Before(run.to); from = from.Add(settlementPeriodDuration) {
+			ft.attempted[from] = true
+		}
+		ft.mutex.Unlock()
+	}
+
+	return nil
+}
+
+// Run calls Snapshot and UpdateActuals every interval, until ctx is cancelled, then returns ctx.Err()
+//
+// Failures (after any retries allowed by the RetryPolicy of the APIHandler) don't stop it, so that an outage of the API doesn't
+// end tracking; they are passed to onError, if it isn't nil, and tried again at the next interval.
+// An error is returned straight away if interval isn't positive.
+func (ft *ForecastTracker) Run(ctx context.Context, interval time.Duration, onError func(err error)) error {
+	if interval <= 0 {
+		return fmt.Errorf("Invalid interval %s; must be positive", interval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, snapshotErr := ft.Snapshot(ctx)
+		updateErr := ft.UpdateActuals(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		for _, err := range []error{snapshotErr, updateErr} {
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Vintages returns the tracked vintages, in the order they were fetched
+func (ft *ForecastTracker) Vintages() []*ForecastVintage {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	return append([]*ForecastVintage(nil), ft.vintages...)
+}
+
+// Revisions returns the forecast for the settlement period starting at from in each of the tracked vintages which has one,
+// in the order they were fetched
+func (ft *ForecastTracker) Revisions(from time.Time) []ForecastRevision {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	var revisions []ForecastRevision
+	for _, vintage := range ft.vintages {
+		for _, entry := range vintage.Forecast {
+			if forecast, ok := entry.ForecastValue(); ok && entry.From.Equal(from) {
+				revisions = append(revisions, ForecastRevision{Fetched: vintage.Fetched, Forecast: forecast, Index: entry.Index})
+			}
+		}
+	}
+
+	return revisions
+}
+
+// Accuracy returns the accuracy of the tracked forecasts for which the actual intensity is known, grouped by lead time
+// (how long before the start of the period the forecast was fetched) into buckets of bucketSize. Forecasts fetched after
+// the period started are in the first bucket. Only buckets with forecasts are included, in order of LeadTime.
+// If bucketSize isn't positive all forecasts are in a single bucket, with a LeadTime of 0.
+func (ft *ForecastTracker) Accuracy(bucketSize time.Duration) []*ForecastAccuracy {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	type errorSums struct {
+		absolute float64
+		squared  float64
+		signed   float64
+		hits     int
+	}
+
+	buckets := make(map[int]*ForecastAccuracy)
+	sums := make(map[int]*errorSums)
+	for _, vintage := range ft.vintages {
+		for _, entry := range vintage.Forecast {
+			actual, ok := ft.actuals[entry.From.UTC()]
+			forecast, forecastKnown := entry.ForecastValue()
+			if !ok || !forecastKnown {
+				continue
+			}
+
+			bucket := 0
+			if leadTime := entry.From.Sub(vintage.Fetched); leadTime > 0 && bucketSize > 0 {
+				bucket = int(leadTime / bucketSize)
+			}
+
+			if buckets[bucket] == nil {
+				buckets[bucket] = &ForecastAccuracy{LeadTime: time.Duration(bucket) * bucketSize}
+				sums[bucket] = &errorSums{}
+			}
+
+			difference := float64(forecast - actual.Actual)
+			buckets[bucket].Count++
+			sums[bucket].absolute += math.Abs(difference)
+			sums[bucket].squared += difference * difference
+			sums[bucket].signed += difference
+
+			if entry.Index == ClassifyIntensity(float64(actual.Actual), entry.From.Year()) {
+				sums[bucket].hits++
+			}
+		}
+	}
+
+	accuracies := make([]*ForecastAccuracy, 0, len(buckets))
+	for bucket, accuracy := range buckets {
+		count := float64(accuracy.Count)
+		accuracy.MAE = sums[bucket].absolute / count
+		accuracy.RMSE = math.Sqrt(sums[bucket].squared / count)
+		accuracy.Bias = sums[bucket].signed / count
+		accuracy.IndexHitRate = float64(sums[bucket].hits) / count
+		accuracies = append(accuracies, accuracy)
+	}
+
+	sort.Slice(accuracies, func(i, j int) bool { return accuracies[i].LeadTime < accuracies[j].LeadTime })
+	return accuracies
+}
+
+// Prune discards vintages fetched before before, and actuals for periods which started before it
+func (ft *ForecastTracker) Prune(before time.Time) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	index := sort.Search(len(ft.vintages), func(i int) bool { return !ft.vintages[i].Fetched.Before(before) })
+	ft.vintages = append([]*ForecastVintage(nil), ft.vintages[index:]...)
+
+	for from := range ft.actuals {
+		if from.Before(before) {
+			delete(ft.actuals, from)
+		}
+	}
+
+	for from := range ft.attempted {
+		if from.Before(before) {
+			delete(ft.attempted, from)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package carbonintensity
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForecastAccuracy(t *testing.T) {
+	tracker := NewForecastTracker(NewCarbonIntensityAPIHandler())
+	period := time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC)
+
+	forecastFor := func(value int, index IntensityIndex) []*Intensity {
+		return []*Intensity{{From: period, To: period.Add(30 * time.Minute), Forecast: value, Actual: -1, Index: index}}
+	}
+
+	// Vintages 2 to 3 hours ahead are 40 and 20 over, under an hour ahead are 10 under and spot on, and one after the period
+	// started is unknown and ignored
+	tracker.AddVintage(&ForecastVintage{Fetched: period.Add(-45 * time.Minute), Forecast: forecastFor(190, IndexModerate)})
+	tracker.AddVintage(&ForecastVintage{Fetched: period.Add(-165 * time.Minute), Forecast: forecastFor(240, IndexHigh)})
+	tracker.AddVintage(&ForecastVintage{Fetched: period.Add(-135 * time.Minute), Forecast: forecastFor(220, IndexHigh)})
+	tracker.AddVintage(&ForecastVintage{Fetched: period.Add(-30 * time.Minute), Forecast: forecastFor(200, IndexModerate)})
+	tracker.AddVintage(&ForecastVintage{Fetched: period.Add(10 * time.Minute), Forecast: forecastFor(-1, IndexUnknown)})
+
+	// No actual yet
+	assert.Equal(t, 0, len(tracker.Accuracy(time.Hour)))
+
+	// The same period, with times in another zone
+	utcPlusOne := time.FixedZone("UTC+1", 60*60)
+	tracker.AddActuals([]*Intensity{{From: period.In(utcPlusOne), To: period.Add(30 * time.Minute).In(utcPlusOne), Forecast: 200,
+		Actual: 200, Index: IndexModerate}})
+
+	accuracies := tracker.Accuracy(time.Hour)
+	if assert.Equal(t, 2, len(accuracies)) {
+		assert.Equal(t, time.Duration(0), accuracies[0].LeadTime)
+		assert.Equal(t, 2, accuracies[0].Count)
+		assert.InDelta(t, 5, accuracies[0].MAE, 0.001)
+		assert.InDelta(t, math.Sqrt(50), accuracies[0].RMSE, 0.001)
+		assert.InDelta(t, -5, accuracies[0].Bias, 0.001)
+		assert.InDelta(t, 1, accuracies[0].IndexHitRate, 0.001)
+
+		assert.Equal(t, 2*time.Hour, accuracies[1].LeadTime)
+		assert.Equal(t, 2, accuracies[1].Count)
+		assert.InDelta(t, 30, accuracies[1].MAE, 0.001)
+		assert.InDelta(t, math.Sqrt(1000), accuracies[1].RMSE, 0.001)
+		assert.InDelta(t, 30, accuracies[1].Bias, 0.001)
+		assert.InDelta(t, 0, accuracies[1].IndexHitRate, 0.001)
+	}
+
+	// Without a bucket size everything is in one bucket
+	for _, bucketSize := range []time.Duration{0, -time.Hour} {
+		accuracies = tracker.Accuracy(bucketSize)
+		if assert.Equal(t, 1, len(accuracies)) {
+			assert.Equal(t, time.Duration(0), accuracies[0].LeadTime)
+			assert.Equal(t, 4, accuracies[0].Count)
+			assert.InDelta(t, 17.5, accuracies[0].MAE, 0.001)
+		}
+	}
+
+	// Revisions are in the order fetched, whatever order they were added in
+	revisions := tracker.Revisions(period)
+	if assert.Equal(t, 4, len(revisions)) {
+		assert.Equal(t, 240, revisions[0].Forecast)
+		assert.Equal(t, 220, revisions[1].Forecast)
+		assert.Equal(t, 190, revisions[2].Forecast)
+		assert.Equal(t, 200, revisions[3].Forecast)
+		assert.Equal(t, period.Add(-30*time.Minute), revisions[3].Fetched)
+	}
+
+	tracker.Prune(period.Add(-time.Hour))
+	assert.Equal(t, 3, len(tracker.Vintages()))
+	assert.Equal(t, 2, len(tracker.Revisions(period)))
+
+	tracker.Prune(period.Add(time.Hour))
+	assert.Equal(t, 0, len(tracker.Accuracy(time.Hour)))
+}
+
+func TestForecastTrackerFetching(t *testing.T) {
+	// Forecasts are always 250, and actuals 200 for periods before 12:00
+	server := startTestPeriodServer(&testPeriodServer{
+		forecast: func(from time.Time) int { return 250 },
+		actual:   func(from time.Time) (int, bool) { return 200, from.Hour() < 12 },
+	})
+	defer server.Close()
+
+	tracker := NewForecastTracker(NewCarbonIntensityAPIHandler(WithBaseURL(server.URL)))
+	now := time.Date(2018, 1, 20, 10, 10, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	vintage, err := tracker.Snapshot(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, now, vintage.Fetched)
+	// The period in progress and the 48 hours after it
+	assert.Equal(t, 97, len(vintage.Forecast))
+
+	// Nothing has ended yet, so there are no actuals to fetch
+	assert.Nil(t, tracker.UpdateActuals(context.Background()))
+	assert.Equal(t, []string{"/intensity/2018-01-20T10:10Z/fw48h"}, server.paths())
+
+	now = time.Date(2018, 1, 20, 13, 10, 0, 0, time.UTC)
+	assert.Nil(t, tracker.UpdateActuals(context.Background()))
+	assert.Equal(t, "/intensity/2018-01-20T10:00Z/2018-01-20T13:00Z", server.paths()[1])
+
+	// Actuals are only known for the periods before 12:00
+	accuracies := tracker.Accuracy(time.Hour)
+	if assert.Equal(t, 2, len(accuracies)) {
+		assert.Equal(t, 3, accuracies[0].Count)
+		assert.Equal(t, 1, accuracies[1].Count)
+		assert.InDelta(t, 50, accuracies[1].Bias, 0.001)
+	}
+
+	// Periods which still don't have actuals are fetched again
+	assert.Nil(t, tracker.UpdateActuals(context.Background()))
+	assert.Equal(t, "/intensity/2018-01-20T12:00Z/2018-01-20T13:00Z", server.paths()[2])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.True(t, errors.Is(tracker.Run(ctx, time.Hour, nil), context.Canceled))
+}
+
+func TestForecastTrackerMissingActuals(t *testing.T) {
+	// Every period has an actual, except 10:30 which never gets one
+	server := startTestPeriodServer(&testPeriodServer{
+		actual: func(from time.Time) (int, bool) { return 200, from.Hour() != 10 || from.Minute() != 30 },
+	})
+	defer server.Close()
+
+	tracker := NewForecastTracker(NewCarbonIntensityAPIHandler(WithBaseURL(server.URL)))
+	from := time.Date(2018, 1, 20, 10, 0, 0, 0, time.UTC)
+	var forecast []*Intensity
+	for period := 0; period < 8; period++ {
+		periodFrom := from.Add(time.Duration(period) * settlementPeriodDuration)
+		forecast = append(forecast, &Intensity{From: periodFrom, To: periodFrom.Add(settlementPeriodDuration), Forecast: 250, Actual: -1,
+			Index: IndexHigh})
+	}
+	tracker.AddVintage(&ForecastVintage{Fetched: from.Add(-time.Hour), Forecast: forecast})
+
+	now := time.Date(2018, 1, 20, 12, 10, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	assert.Nil(t, tracker.UpdateActuals(context.Background()))
+	assert.Equal(t, []string{"/intensity/2018-01-20T10:00Z/2018-01-20T12:00Z"}, server.paths())
+
+	// Only the period without an actual, and those which have since ended, are fetched again
+	now = time.Date(2018, 1, 20, 13, 10, 0, 0, time.UTC)
+	assert.Nil(t, tracker.UpdateActuals(context.Background()))
+	assert.Equal(t, []string{"/intensity/2018-01-20T10:30Z/2018-01-20T11:00Z", "/intensity/2018-01-20T12:00Z/2018-01-20T13:00Z"},
+		server.paths()[1:])
+
+	// A day after it ended the period without an actual is given up on, but those which haven't been fetched yet still are, once
+	now = time.Date(2018, 1, 22, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, tracker.UpdateActuals(context.Background()))
+	}
+	assert.Equal(t, []string{"/intensity/2018-01-20T13:00Z/2018-01-20T14:00Z"}, server.paths()[3:])
+}
+
+func TestForecastTrackerRunErrors(t *testing.T) {
+	// The first snapshot fails, then the API recovers
+	server := startTestPeriodServer(&testPeriodServer{failures: 1})
+	defer server.Close()
+
+	tracker := NewForecastTracker(NewCarbonIntensityAPIHandler(WithBaseURL(server.URL)))
+	tracker.now = func() time.Time { return time.Date(2018, 1, 20, 12, 10, 0, 0, time.UTC) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 10)
+	done := make(chan error)
+	go func() {
+		done <- tracker.Run(ctx, time.Millisecond, func(err error) { errs <- err })
+	}()
+
+	// The failure is reported, and tracking carries on
+	select {
+	case err := <-errs:
+		var apiErr *APIError
+		assert.True(t, errors.As(err, &apiErr))
+	case <-time.After(5 * time.Second):
+		t.Fatal("No error reported")
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); len(tracker.Vintages()) == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, len(tracker.Vintages()) > 0)
+
+	cancel()
+	assert.True(t, errors.Is(<-done, context.Canceled))
+
+	// An invalid interval is an error, without fetching anything
+	fetched := server.requestCount()
+	for _, interval := range []time.Duration{0, -time.Minute} {
+		err := tracker.Run(context.Background(), interval, nil)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "interval")
+		}
+	}
+	assert.Equal(t, fetched, server.requestCount())
+}
@@ -0,0 +1,170 @@
+package carbonintensity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type generationResponse struct {
+	entryDecoder
+	entries []*GenerationMix
+}
+
+// GenerationMix represents the mix of fuel types used for electricity generation for a period of time, given by From and To
+//
+// Each fuel type is given as a percentage of the total generation.
+// Storage is only reported by the regional part of the API.
+type GenerationMix struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Biomass float64   `json:"biomass"`
+	Coal    float64   `json:"coal"`
+	Imports float64   `json:"imports"`
+	Gas     float64   `json:"gas"`
+	Nuclear float64   `json:"nuclear"`
+	Other   float64   `json:"other"`
+	Hydro   float64   `json:"hydro"`
+	Solar   float64   `json:"solar"`
+	Wind    float64   `json:"wind"`
+	Storage float64   `json:"storage"`
+}
+
+func unmarshalGenerationMix(fuels []apiFuel, from time.Time, to time.Time) *GenerationMix {
+	mix := &GenerationMix{From: from, To: to}
+
+	for _, fuelEntry := range fuels {
+		switch fuelEntry.Fuel {
+		case "biomass":
+			mix.Biomass = fuelEntry.Perc
+		case "coal":
+			mix.Coal = fuelEntry.Perc
+		case "imports":
+			mix.Imports = fuelEntry.Perc
+		case "gas":
+			mix.Gas = fuelEntry.Perc
+		case "nuclear":
+			mix.Nuclear = fuelEntry.Perc
+		case "other":
+			mix.Other = fuelEntry.Perc
+		case "hydro":
+			mix.Hydro = fuelEntry.Perc
+		case "solar":
+			mix.Solar = fuelEntry.Perc
+		case "wind":
+			mix.Wind = fuelEntry.Perc
+		case "storage":
+			mix.Storage = fuelEntry.Perc
+		}
+	}
+
+	return mix
+}
+
+// The current generation mix resource returns a single object as "data", rather than a list of one object
+func (gr *generationResponse) UnmarshalJSON(data []byte) error {
+	return gr.decodeEntries(data, func(index int, rawEntry json.RawMessage) error {
+		var decodedEntry apiGenerationEntry
+		if err := json.Unmarshal(rawEntry, &decodedEntry); err != nil {
+			return err
+		}
+
+		fromTime, toTime, err := decodedEntry.times()
+		if err != nil {
+			return err
+		}
+
+		gr.entries = append(gr.entries, unmarshalGenerationMix(decodedEntry.GenerationMix, fromTime, toTime))
+		return nil
+	})
+}
+
+func (gm *GenerationMix) String() string {
+	return fmt.Sprintf("%s -> %s {biomass: %.1f%%, coal: %.1f%%, imports: %.1f%%, gas: %.1f%%, nuclear: %.1f%%, other: %.1f%%, "+
+		"hydro: %.1f%%, solar: %.1f%%, wind: %.1f%%, storage: %.1f%%}", gm.From.Format(natGridTimeFormat), gm.To.Format(natGridTimeFormat),
+		gm.Biomass, gm.Coal, gm.Imports, gm.Gas, gm.Nuclear, gm.Other, gm.Hydro, gm.Solar, gm.Wind, gm.Storage)
+}
+
+// EstimateIntensity returns an estimate of the carbon intensity of the generation mix in gCO2/KWh, using the intensity
+// factors for each fuel type given by factors
+//
+// The generation mix doesn't distinguish between all of the fuel types that factors does, so;
+// Imports use the average of the Dutch, French and Irish imports factors,
+// Gas uses the combined cycle factor (the large majority of gas generation) and
+// Storage uses the pumped storage factor.
+func (gm *GenerationMix) EstimateIntensity(factors *IntensityFactors) float64 {
+	importsFactor := float64(factors.DutchImports+factors.FrenchImports+factors.IrishImports) / 3
+
+	weightedSum := gm.Biomass*float64(factors.Biomass) +
+		gm.Coal*float64(factors.Coal) +
+		gm.Imports*importsFactor +
+		gm.Gas*float64(factors.GasCombinedCycle) +
+		gm.Nuclear*float64(factors.Nuclear) +
+		gm.Other*float64(factors.Other) +
+		gm.Hydro*float64(factors.Hydro) +
+		gm.Solar*float64(factors.Solar) +
+		gm.Wind*float64(factors.Wind) +
+		gm.Storage*float64(factors.PumpedStorage)
+
+	return weightedSum / 100
+}
+
+func (ah *APIHandler) getGenerationResponse(ctx context.Context, resource string) ([]*GenerationMix, error) {
+	responseBytes, err := ah.getAPIResponse(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	response := generationResponse{entryDecoder: entryDecoder{lenient: ah.lenientDecoding}}
+	if err := unmarshalAPIResponse(responseBytes, &response); err != nil {
+		return nil, err
+	}
+
+	return response.entries, response.err()
+}
+
+// GetCurrentGenerationMix returns a GenerationMix object, for the current 30 minute settlement period
+func (ah *APIHandler) GetCurrentGenerationMix() (*GenerationMix, error) {
+	return ah.GetCurrentGenerationMixContext(context.Background())
+}
+
+// GetCurrentGenerationMixContext is the same as GetCurrentGenerationMix, but the request is made with the context ctx
+func (ah *APIHandler) GetCurrentGenerationMixContext(ctx context.Context) (*GenerationMix, error) {
+	entries, err := ah.getGenerationResponse(ctx, "/generation")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) != 1 {
+		return nil, fmt.Errorf("%w; unexpected number of entries (%d) for /generation", ErrUnexpectedResponse, len(entries))
+	}
+
+	return entries[0], nil
+}
+
+// GetGenerationMixBetween returns an array of GenerationMix objects, for all 30 minute settlement periods between from and to
+func (ah *APIHandler) GetGenerationMixBetween(from time.Time, to time.Time) ([]*GenerationMix, error) {
+	return ah.GetGenerationMixBetweenContext(context.Background(), from, to)
+}
+
+// GetGenerationMixBetweenContext is the same as GetGenerationMixBetween, but the request is made with the context ctx
+func (ah *APIHandler) GetGenerationMixBetweenContext(ctx context.Context, from time.Time, to time.Time) ([]*GenerationMix, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w; from (%s) must be strictly earlier than to (%s)", ErrInvalidRange, from.String(), to.String())
+	}
+
+	return ah.getGenerationResponse(ctx, fmt.Sprintf("/generation/%s/%s", from.Format(natGridTimeFormat), to.Format(natGridTimeFormat)))
+}
+
+// GetPrior24HourGenerationMix returns an array of GenerationMix objects, for all 30 minute settlement periods between from-24h and from
+//
+// While this could be implemented using GetGenerationMixBetween it uses the dedicated /generation/{from}/pt24h resource
+func (ah *APIHandler) GetPrior24HourGenerationMix(from time.Time) ([]*GenerationMix, error) {
+	return ah.GetPrior24HourGenerationMixContext(context.Background(), from)
+}
+
+// GetPrior24HourGenerationMixContext is the same as GetPrior24HourGenerationMix, but the request is made with the context ctx
+func (ah *APIHandler) GetPrior24HourGenerationMixContext(ctx context.Context, from time.Time) ([]*GenerationMix, error) {
+	return ah.getGenerationResponse(ctx, fmt.Sprintf("/generation/%s/pt24h", from.Format(natGridTimeFormat)))
+}
@@ -0,0 +1,96 @@
+package carbonintensity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testCurrentGenerationResponse = `{"data":{"from":"2018-01-20T12:00Z","to":"2018-01-20T12:30Z","generationmix":[
+		{"fuel":"gas","perc":43.6},{"fuel":"coal","perc":0.7},{"fuel":"biomass","perc":4.2},{"fuel":"nuclear","perc":17.6},
+		{"fuel":"hydro","perc":2.2},{"fuel":"imports","perc":6.5},{"fuel":"other","perc":0.3},{"fuel":"wind","perc":6.8},
+		{"fuel":"solar","perc":18.1}]}}`
+
+	testGenerationRangeResponse = `{"data":[
+		{"from":"2018-01-20T12:00Z","to":"2018-01-20T12:30Z","generationmix":[{"fuel":"gas","perc":43.6},{"fuel":"wind","perc":56.4}]},
+		{"from":"2018-01-20T12:30Z","to":"2018-01-20T13:00Z","generationmix":[{"fuel":"gas","perc":40.1},{"fuel":"wind","perc":59.9}]}]}`
+)
+
+func TestCurrentGenerationMix(t *testing.T) {
+	handler, closeServer := newTestAPIHandler(t, map[string]string{
+		"/generation": testCurrentGenerationResponse,
+	})
+	defer closeServer()
+
+	mix, err := handler.GetCurrentGenerationMix()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC), mix.From)
+	assert.Equal(t, time.Date(2018, 1, 20, 12, 30, 0, 0, time.UTC), mix.To)
+	assert.Equal(t, 43.6, mix.Gas)
+	assert.Equal(t, 0.7, mix.Coal)
+	assert.Equal(t, 4.2, mix.Biomass)
+	assert.Equal(t, 17.6, mix.Nuclear)
+	assert.Equal(t, 2.2, mix.Hydro)
+	assert.Equal(t, 6.5, mix.Imports)
+	assert.Equal(t, 0.3, mix.Other)
+	assert.Equal(t, 6.8, mix.Wind)
+	assert.Equal(t, 18.1, mix.Solar)
+	assert.Equal(t, 0.0, mix.Storage)
+	t.Logf("%v\n", mix)
+}
+
+func TestGenerationMixBetween(t *testing.T) {
+	from := time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC)
+	to := time.Date(2018, 1, 20, 13, 0, 0, 0, time.UTC)
+
+	handler, closeServer := newTestAPIHandler(t, map[string]string{
+		"/generation/2018-01-20T12:00Z/2018-01-20T13:00Z": testGenerationRangeResponse,
+		"/generation/2018-01-20T13:00Z/pt24h":             testGenerationRangeResponse,
+	})
+	defer closeServer()
+
+	mixArr, err := handler.GetGenerationMixBetween(from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(mixArr))
+	assert.Equal(t, from, mixArr[0].From)
+	assert.Equal(t, 43.6, mixArr[0].Gas)
+	assert.Equal(t, 59.9, mixArr[1].Wind)
+
+	mixArr, err = handler.GetPrior24HourGenerationMix(to)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(mixArr))
+
+	// to and from equal, should return error
+	mixArr, err = handler.GetGenerationMixBetween(from, from)
+	assert.Error(t, err)
+}
+
+func TestGenerationMixEstimateIntensity(t *testing.T) {
+	factors := &IntensityFactors{
+		Biomass:          120,
+		Coal:             937,
+		DutchImports:     474,
+		FrenchImports:    53,
+		GasCombinedCycle: 394,
+		GasOpenCycle:     651,
+		Hydro:            0,
+		IrishImports:     458,
+		Nuclear:          0,
+		Oil:              935,
+		Other:            300,
+		PumpedStorage:    0,
+		Solar:            0,
+		Wind:             0,
+	}
+
+	mix := &GenerationMix{Gas: 50, Wind: 50}
+	assert.InDelta(t, 197, mix.EstimateIntensity(factors), 0.001)
+
+	mix = &GenerationMix{Coal: 10, Imports: 30, Nuclear: 20, Biomass: 40}
+	assert.InDelta(t, 93.7+98.5+48, mix.EstimateIntensity(factors), 0.001)
+
+	mix = &GenerationMix{Wind: 60, Solar: 20, Hydro: 10, Storage: 10}
+	assert.InDelta(t, 0, mix.EstimateIntensity(factors), 0.001)
+}
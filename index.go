@@ -0,0 +1,148 @@
+package carbonintensity
+
+import (
+	"errors"
+	"fmt"
+)
+
+// IntensityIndex is the rating the API gives to a carbon intensity, from IndexVeryLow to IndexVeryHigh
+//
+// Indexes are ordered, so IndexVeryLow < IndexLow < IndexModerate < IndexHigh < IndexVeryHigh.
+// The zero value is IndexUnknown, which is less than all others.
+type IntensityIndex int
+
+// The valid values of IntensityIndex
+const (
+	IndexUnknown IntensityIndex = iota
+	IndexVeryLow
+	IndexLow
+	IndexModerate
+	IndexHigh
+	IndexVeryHigh
+)
+
+// ErrUnknownIndex is returned when parsing a string which isn't a known IntensityIndex
+var ErrUnknownIndex = errors.New("Unknown intensity index")
+
+var indexNames = map[IntensityIndex]string{
+	IndexVeryLow:  "very low",
+	IndexLow:      "low",
+	IndexModerate: "moderate",
+	IndexHigh:     "high",
+	IndexVeryHigh: "very high",
+}
+
+// ParseIntensityIndex returns the IntensityIndex named by s, as used by the API (e.g. "very low")
+//
+// An error wrapping ErrUnknownIndex is returned if s isn't the name of an IntensityIndex.
+func ParseIntensityIndex(s string) (IntensityIndex, error) {
+	for index, name := range indexNames {
+		if name == s {
+			return index, nil
+		}
+	}
+
+	return IndexUnknown, fmt.Errorf("%w %q", ErrUnknownIndex, s)
+}
+
+// String returns the name of the index as used by the API (e.g. "very low"), or "unknown" for IndexUnknown and invalid values
+func (ii IntensityIndex) String() string {
+	if name, ok := indexNames[ii]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler, using the name of the index as used by the API
+func (ii IntensityIndex) MarshalText() ([]byte, error) {
+	if _, ok := indexNames[ii]; !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownIndex, int(ii))
+	}
+
+	return []byte(ii.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting the name of the index as used by the API
+func (ii *IntensityIndex) UnmarshalText(text []byte) error {
+	index, err := ParseIntensityIndex(string(text))
+	if err != nil {
+		return err
+	}
+
+	*ii = index
+	return nil
+}
+
+// IndexBands gives the lowest carbon intensity, in gCO2/KWh, rated as each IntensityIndex above IndexVeryLow.
+// Anything lower than Low is rated IndexVeryLow.
+//
+// The bands used by the API are given by IndexBandsForYear, but any bands can be used with Classify.
+type IndexBands struct {
+	Low      float64
+	Moderate float64
+	High     float64
+	VeryHigh float64
+}
+
+// The bands published by National Grid, which get lower each year in line with decarbonisation targets.
+// See the "Carbon Intensity Forecast Methodology" document at https://carbonintensity.org.uk/
+var publishedIndexBands = map[int]IndexBands{
+	2017: {Low: 100, Moderate: 180, High: 280, VeryHigh: 380},
+	2018: {Low: 95, Moderate: 170, High: 265, VeryHigh: 360},
+	2019: {Low: 90, Moderate: 160, High: 250, VeryHigh: 340},
+	2020: {Low: 85, Moderate: 150, High: 235, VeryHigh: 320},
+	2021: {Low: 80, Moderate: 140, High: 220, VeryHigh: 300},
+	2022: {Low: 75, Moderate: 130, High: 205, VeryHigh: 280},
+	2023: {Low: 70, Moderate: 120, High: 190, VeryHigh: 260},
+	2024: {Low: 65, Moderate: 110, High: 175, VeryHigh: 240},
+	2025: {Low: 60, Moderate: 100, High: 160, VeryHigh: 220},
+	2026: {Low: 55, Moderate: 90, High: 145, VeryHigh: 200},
+	2027: {Low: 50, Moderate: 80, High: 130, VeryHigh: 180},
+	2028: {Low: 45, Moderate: 70, High: 115, VeryHigh: 160},
+	2029: {Low: 40, Moderate: 60, High: 100, VeryHigh: 140},
+	2030: {Low: 35, Moderate: 50, High: 85, VeryHigh: 120},
+}
+
+const (
+	firstPublishedIndexBandsYear = 2017
+	lastPublishedIndexBandsYear  = 2030
+)
+
+// IndexBandsForYear returns the bands used by the API to rate carbon intensities in year
+//
+// Bands are published for 2017 to 2030. Earlier years use the 2017 bands and later years use the 2030 bands.
+func IndexBandsForYear(year int) IndexBands {
+	if year < firstPublishedIndexBandsYear {
+		year = firstPublishedIndexBandsYear
+	}
+
+	if year > lastPublishedIndexBandsYear {
+		year = lastPublishedIndexBandsYear
+	}
+
+	return publishedIndexBands[year]
+}
+
+// Classify returns the IntensityIndex of intensity, in gCO2/KWh, according to the bands
+func (ib IndexBands) Classify(intensity float64) IntensityIndex {
+	switch {
+	case intensity >= ib.VeryHigh:
+		return IndexVeryHigh
+	case intensity >= ib.High:
+		return IndexHigh
+	case intensity >= ib.Moderate:
+		return IndexModerate
+	case intensity >= ib.Low:
+		return IndexLow
+	default:
+		return IndexVeryLow
+	}
+}
+
+// ClassifyIntensity returns the IntensityIndex the API would give to intensity, in gCO2/KWh, in year
+//
+// This allows intensities calculated locally (e.g. with GenerationMix.EstimateIntensity) to be rated the same way as the API does.
+func ClassifyIntensity(intensity float64, year int) IntensityIndex {
+	return IndexBandsForYear(year).Classify(intensity)
+}
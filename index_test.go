@@ -0,0 +1,132 @@
+package carbonintensity
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntensityIndex(t *testing.T) {
+	assert.True(t, IndexUnknown < IndexVeryLow)
+	assert.True(t, IndexVeryLow < IndexLow)
+	assert.True(t, IndexLow < IndexModerate)
+	assert.True(t, IndexModerate < IndexHigh)
+	assert.True(t, IndexHigh < IndexVeryHigh)
+
+	for _, index := range []IntensityIndex{IndexVeryLow, IndexLow, IndexModerate, IndexHigh, IndexVeryHigh} {
+		parsed, err := ParseIntensityIndex(index.String())
+		assert.NoError(t, err)
+		assert.Equal(t, index, parsed)
+
+		text, err := index.MarshalText()
+		assert.NoError(t, err)
+
+		var unmarshalled IntensityIndex
+		assert.NoError(t, unmarshalled.UnmarshalText(text))
+		assert.Equal(t, index, unmarshalled)
+	}
+
+	assert.Equal(t, "very low", IndexVeryLow.String())
+	assert.Equal(t, "very high", IndexVeryHigh.String())
+	assert.Equal(t, "unknown", IndexUnknown.String())
+	assert.Equal(t, "unknown", IntensityIndex(42).String())
+
+	_, err := ParseIntensityIndex("extremely high")
+	assert.True(t, errors.Is(err, ErrUnknownIndex))
+
+	_, err = IndexUnknown.MarshalText()
+	assert.True(t, errors.Is(err, ErrUnknownIndex))
+
+	// Used as JSON strings and map keys
+	encoded, err := json.Marshal(map[IntensityIndex]IntensityIndex{IndexLow: IndexHigh})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"low":"high"}`, string(encoded))
+
+	var decoded map[IntensityIndex]IntensityIndex
+	assert.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, IndexHigh, decoded[IndexLow])
+
+	assert.Error(t, json.Unmarshal([]byte(`["extremely high"]`), &[]IntensityIndex{}))
+}
+
+func TestUnknownIndexFromAPI(t *testing.T) {
+	handler, closeServer := newTestAPIHandler(t, map[string]string{
+		"/intensity": `{"data":[{"from":"2018-01-20T12:00Z","to":"2018-01-20T12:30Z","intensity":{"forecast":266,"actual":263,"index":"extremely high"}}]}`,
+	})
+	defer closeServer()
+
+	_, err := handler.GetCurrentIntensity()
+	assert.True(t, errors.Is(err, ErrUnexpectedResponse))
+	assert.Contains(t, err.Error(), "extremely high")
+}
+
+func TestClassifyIntensity(t *testing.T) {
+	assert.Equal(t, IndexVeryLow, ClassifyIntensity(0, 2018))
+	assert.Equal(t, IndexVeryLow, ClassifyIntensity(94, 2018))
+	assert.Equal(t, IndexVeryLow, ClassifyIntensity(94.9, 2018))
+	assert.Equal(t, IndexLow, ClassifyIntensity(95, 2018))
+	assert.Equal(t, IndexLow, ClassifyIntensity(169, 2018))
+	assert.Equal(t, IndexModerate, ClassifyIntensity(170, 2018))
+	assert.Equal(t, IndexModerate, ClassifyIntensity(264, 2018))
+	assert.Equal(t, IndexHigh, ClassifyIntensity(265, 2018))
+	assert.Equal(t, IndexHigh, ClassifyIntensity(359, 2018))
+	assert.Equal(t, IndexVeryHigh, ClassifyIntensity(360, 2018))
+
+	// The same intensity is rated higher as the bands get lower each year
+	assert.Equal(t, IndexLow, ClassifyIntensity(150, 2019))
+	assert.Equal(t, IndexModerate, ClassifyIntensity(150, 2020))
+	assert.Equal(t, IndexHigh, ClassifyIntensity(150, 2026))
+	assert.Equal(t, IndexVeryHigh, ClassifyIntensity(150, 2030))
+
+	// Years outside those published use the nearest published bands
+	assert.Equal(t, IndexBandsForYear(2017), IndexBandsForYear(2010))
+	assert.Equal(t, IndexBandsForYear(2030), IndexBandsForYear(2050))
+
+	// Custom bands
+	bands := IndexBands{Low: 10, Moderate: 20, High: 30, VeryHigh: 40}
+	assert.Equal(t, IndexVeryLow, bands.Classify(9))
+	assert.Equal(t, IndexLow, bands.Classify(10))
+	assert.Equal(t, IndexModerate, bands.Classify(25))
+	assert.Equal(t, IndexHigh, bands.Classify(39.9))
+	assert.Equal(t, IndexVeryHigh, bands.Classify(1000))
+}
+
+// classifyIntensityEntries checks ClassifyIntensity agrees with the Index the API gave each of intensityArr.
+// The index is based on the actual intensity where there is one, otherwise the forecast.
+func classifyIntensityEntries(t *testing.T, intensityArr []*Intensity) {
+	for _, intensity := range intensityArr {
+		value, ok := intensity.Best()
+		assert.True(t, ok)
+		assert.Equal(t, intensity.Index, ClassifyIntensity(float64(value), intensity.From.Year()), intensity.String())
+	}
+}
+
+func TestClassifyIntensityAgreesWithAPI(t *testing.T) {
+	from := time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC)
+
+	handler, closeServer := newTestAPIHandler(t, map[string]string{
+		"/intensity/2018-01-20T12:00Z/2018-01-20T15:00Z": `{"data":[
+			{"from":"2018-01-20T12:00Z","to":"2018-01-20T12:30Z","intensity":{"forecast":266,"actual":263,"index":"moderate"}},
+			{"from":"2018-01-20T12:30Z","to":"2018-01-20T13:00Z","intensity":{"forecast":262,"actual":265,"index":"high"}},
+			{"from":"2018-01-20T13:00Z","to":"2018-01-20T13:30Z","intensity":{"forecast":170,"actual":169,"index":"low"}},
+			{"from":"2018-01-20T13:30Z","to":"2018-01-20T14:00Z","intensity":{"forecast":94,"actual":null,"index":"very low"}},
+			{"from":"2018-01-20T14:00Z","to":"2018-01-20T14:30Z","intensity":{"forecast":361,"actual":null,"index":"very high"}}]}`,
+	})
+	defer closeServer()
+
+	intensityArr, err := handler.GetIntensityBetween(from, from.Add(3*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(intensityArr))
+	classifyIntensityEntries(t, intensityArr)
+}
+
+func TestClassifyIntensityAgreesWithRealAPI(t *testing.T) {
+	handler := newLiveAPIHandler(t)
+
+	intensityArr, err := handler.GetIntensityBetween(liveTestTime.Add(-24*time.Hour), liveTestTime.Add(24*time.Hour))
+	assert.NoError(t, err)
+	classifyIntensityEntries(t, intensityArr)
+}
@@ -0,0 +1,201 @@
+package carbonintensity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// IntensityIterator iterates over the Intensity of every settlement period in a range of any length, fetching the range a chunk
+// at a time as it goes rather than all at once; see IterateIntensityBetween.
+//
+//	it := handler.IterateIntensityBetween(from, to)
+//	defer it.Close()
+//
+//	for it.Next() {
+//		intensity := it.Value()
+//		...
+//	}
+//
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// An IntensityIterator isn't safe for concurrent use.
+type IntensityIterator struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	pages  chan intensityPage
+	done   chan struct{}
+	// Set by the fetching goroutine when every chunk has been sent, before pages is closed
+	complete bool
+	// Set when pages has been closed and drained
+	finished bool
+
+	page     []*Intensity
+	value    *Intensity
+	previous *Intensity
+	warnings chunkWarnings
+	gapErr   error
+	err      error
+	closed   bool
+}
+
+// intensityPage is the result of fetching a single chunk
+type intensityPage struct {
+	entries []*Intensity
+	err     error
+}
+
+// IterateIntensityBetween returns an IntensityIterator over the Intensity of every 30 minute settlement period between from and to
+//
+// Like GetIntensityBetweenChunked the range isn't limited, and is split into 30 day chunks. Chunks are fetched one at a time in
+// the background, with the next chunk fetched while the current one is iterated over, so only a couple of chunks are held at once.
+// Intensities are given in order of From, and periods returned for more than one chunk are only given once.
+//
+// Iteration stops at the first error, which is returned by Err. If WithLenientDecoding is used and entries were skipped,
+// or there are gaps between periods, iteration continues and Err returns a *PartialResponseError or an error wrapping
+// ErrMissingPeriods (as GetIntensityBetweenChunked does) once it has finished.
+//
+// Close should be called when finished with the iterator, to stop fetching chunks if iteration finished early.
+func (ah *APIHandler) IterateIntensityBetween(from time.Time, to time.Time) *IntensityIterator {
+	return ah.IterateIntensityBetweenContext(context.Background(), from, to)
+}
+
+// IterateIntensityBetweenContext is the same as IterateIntensityBetween, but the requests are made with the context ctx.
+// Cancelling ctx stops iteration, with Err returning the reason.
+func (ah *APIHandler) IterateIntensityBetweenContext(ctx context.Context, from time.Time, to time.Time) *IntensityIterator {
+	ctx, cancel := context.WithCancel(ctx)
+	it := &IntensityIterator{
+		ctx:    ctx,
+		cancel: cancel,
+		pages:  make(chan intensityPage, 1),
+		done:   make(chan struct{}),
+	}
+
+	if err := checkChunkRange(from, to); err != nil {
+		it.err = err
+		close(it.pages)
+		close(it.done)
+		return it
+	}
+
+	go it.fetch(ah, splitRange(from, to, maxDateRange))
+	return it
+}
+
+// fetch fetches each of chunks in turn, sending them to the iterator. It stops if the iterator is closed, its context is
+// cancelled, or a chunk fails with anything other than a *PartialResponseError.
+func (it *IntensityIterator) fetch(ah *APIHandler, chunks []timeRange) {
+	defer close(it.done)
+	defer close(it.pages)
+
+	for _, chunk := range chunks {
+		entries, err := ah.GetIntensityBetweenContext(it.ctx, chunk.from, chunk.to)
+
+		select {
+		case it.pages <- intensityPage{entries: entries, err: err}:
+		case <-it.ctx.Done():
+			return
+		}
+
+		var partialErr *PartialResponseError
+		if err != nil && !errors.As(err, &partialErr) {
+			return
+		}
+	}
+
+	it.complete = true
+}
+
+// Next advances the iterator to the next Intensity, which is then returned by Value. It returns false when there are none left,
+// or iteration has stopped due to an error or the iterator being closed.
+func (it *IntensityIterator) Next() bool {
+	it.value = nil
+	if it.closed || it.finished || it.err != nil {
+		return false
+	}
+
+	for {
+		for len(it.page) == 0 {
+			if err := it.ctx.Err(); err != nil {
+				it.err = err
+				return false
+			}
+
+			page, ok := <-it.pages
+			if !ok {
+				it.finished = true
+				if !it.complete {
+					it.err = it.ctx.Err()
+				}
+
+				return false
+			}
+
+			if err := it.warnings.collect(page.err); err != nil {
+				it.err = err
+				return false
+			}
+
+			it.page = page.entries
+			sort.SliceStable(it.page, func(i, j int) bool { return it.page[i].From.Before(it.page[j].From) })
+		}
+
+		intensity := it.page[0]
+		it.page = it.page[1:]
+
+		if it.previous != nil {
+			// Already given for the previous chunk
+			if !intensity.From.After(it.previous.From) {
+				continue
+			}
+
+			if it.gapErr == nil && intensity.From.After(it.previous.To) {
+				it.gapErr = fmt.Errorf("%w; no data between %s and %s", ErrMissingPeriods,
+					it.previous.To.Format(natGridTimeFormat), intensity.From.Format(natGridTimeFormat))
+			}
+		}
+
+		it.value = intensity
+		it.previous = intensity
+		return true
+	}
+}
+
+// Value returns the Intensity the iterator is at, or nil if Next hasn't been called or returned false
+func (it *IntensityIterator) Value() *Intensity {
+	return it.value
+}
+
+// Err returns the error which stopped iteration, if any. Once iteration has finished it also returns any warnings from
+// lenient decoding, or gaps in the data, as described by IterateIntensityBetween.
+func (it *IntensityIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+
+	if !it.finished {
+		return nil
+	}
+
+	// Skipped entries will leave gaps, but the warnings explain those better
+	if err := it.warnings.err(); err != nil {
+		return err
+	}
+
+	return it.gapErr
+}
+
+// Close stops the iterator, cancelling any request in progress. It waits for the background fetching to stop,
+// so no requests are made after it returns.
+func (it *IntensityIterator) Close() error {
+	it.closed = true
+	it.value = nil
+	it.cancel()
+	<-it.done
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package carbonintensity
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIterateIntensityBetween(t *testing.T) {
+	server := newChunkingTestServer(time.Time{})
+	defer server.Close()
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL))
+	from := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(70 * 24 * time.Hour)
+
+	it := handler.IterateIntensityBetween(from, to)
+	defer it.Close()
+
+	assert.Nil(t, it.Value())
+
+	count := 0
+	expectedFrom := from.Add(-settlementPeriodDuration)
+	for it.Next() {
+		if !assert.Equal(t, expectedFrom, it.Value().From) {
+			break
+		}
+
+		expectedFrom = expectedFrom.Add(settlementPeriodDuration)
+		count++
+	}
+
+	assert.Nil(t, it.Err())
+	assert.Nil(t, it.Value())
+	assert.Equal(t, 70*48+1, count)
+	assert.Equal(t, 3, server.requestCount())
+	assert.False(t, it.Next())
+}
+
+func TestIterateIntensityBetweenPrefetch(t *testing.T) {
+	requested := make(chan string, 10)
+	server := startTestPeriodServer(&testPeriodServer{notify: requested})
+	defer server.Close()
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL))
+	from := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	it := handler.IterateIntensityBetween(from, from.Add(150*24*time.Hour))
+	assert.True(t, it.Next())
+
+	// The next chunk is fetched in the background, but not the whole range
+	for _, expected := range []string{"/intensity/2018-01-01T00:00Z/2018-01-31T00:00Z", "/intensity/2018-01-31T00:00Z/2018-03-02T00:00Z"} {
+		select {
+		case path := <-requested:
+			assert.Equal(t, expected, path)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for", expected)
+		}
+	}
+	assert.Equal(t, 2, server.requestCount())
+
+	// No more are fetched once closed
+	it.Close()
+	assert.False(t, it.Next())
+	assert.Nil(t, it.Err())
+	assert.Equal(t, 2, server.requestCount())
+	assert.Equal(t, 0, len(requested))
+}
+
+func TestIterateIntensityBetweenErrors(t *testing.T) {
+	from := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Gaps are reported once iteration has finished
+	missing := from.Add(40 * 24 * time.Hour)
+	server := newChunkingTestServer(missing)
+	defer server.Close()
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL))
+	it := handler.IterateIntensityBetween(from, from.Add(70*24*time.Hour))
+
+	count := 0
+	for it.Next() {
+		assert.Nil(t, it.Err())
+		count++
+	}
+
+	assert.Equal(t, 70*48, count)
+	assert.True(t, errors.Is(it.Err(), ErrMissingPeriods))
+	it.Close()
+
+	it = handler.IterateIntensityBetween(from, from)
+	assert.False(t, it.Next())
+	assert.True(t, errors.Is(it.Err(), ErrInvalidRange))
+	it.Close()
+
+	// Errors stop iteration
+	errorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer errorServer.Close()
+
+	it = NewCarbonIntensityAPIHandler(WithBaseURL(errorServer.URL)).IterateIntensityBetween(from, from.Add(70*24*time.Hour))
+	assert.False(t, it.Next())
+
+	var apiErr *APIError
+	assert.True(t, errors.As(it.Err(), &apiErr))
+	it.Close()
+}
+
+func TestIterateIntensityBetweenCancellation(t *testing.T) {
+	blockingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer blockingServer.Close()
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(blockingServer.URL))
+	from := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it := handler.IterateIntensityBetweenContext(ctx, from, from.Add(70*24*time.Hour))
+	defer it.Close()
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	assert.False(t, it.Next())
+	assert.True(t, time.Since(start) < 5*time.Second)
+	assert.True(t, errors.Is(it.Err(), context.Canceled))
+}
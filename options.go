@@ -0,0 +1,48 @@
+package carbonintensity
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Option configures an APIHandler, see NewCarbonIntensityAPIHandler
+type Option func(*APIHandler)
+
+// WithHTTPClient makes the APIHandler use client for all requests, rather than http.DefaultClient
+//
+// This allows proxies, TLS configuration and timeouts to be set up as required. A nil client is ignored.
+func WithHTTPClient(client *http.Client) Option {
+	return func(ah *APIHandler) {
+		if client != nil {
+			ah.client = client
+		}
+	}
+}
+
+// WithTransport makes the APIHandler send all requests via transport
+//
+// The http.Client in use (http.DefaultClient, unless WithHTTPClient has been applied first) is copied rather than modified.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(ah *APIHandler) {
+		client := *ah.client
+		client.Transport = transport
+		ah.client = &client
+	}
+}
+
+// WithBaseURL makes the APIHandler query the server at baseURL (e.g. "https://carbon-mirror.example.com") rather than the
+// national grid carbon intensity API server
+//
+// This is useful for mirrors of the API and for test servers.
+func WithBaseURL(baseURL string) Option {
+	return func(ah *APIHandler) {
+		ah.serverAddress = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithUserAgent makes the APIHandler send userAgent as the User-Agent header of all requests
+func WithUserAgent(userAgent string) Option {
+	return func(ah *APIHandler) {
+		ah.userAgent = userAgent
+	}
+}
@@ -0,0 +1,69 @@
+package carbonintensity
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCurrentIntensityResponse = `{"data":[{"from":"2018-01-20T12:00Z","to":"2018-01-20T12:30Z","intensity":{"forecast":266,"actual":263,"index":"moderate"}}]}`
+
+type countingTransport struct {
+	requests int
+}
+
+func (ct *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ct.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestOptions(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testCurrentIntensityResponse))
+	}))
+	defer server.Close()
+
+	// Defaults
+	handler := NewCarbonIntensityAPIHandler()
+	assert.Equal(t, natGridServerAddress, handler.serverAddress)
+	assert.Equal(t, http.DefaultClient, handler.client)
+
+	// Base URL, with trailing slash trimmed, and user agent
+	handler = NewCarbonIntensityAPIHandler(WithBaseURL(server.URL+"/"), WithUserAgent("carbon-test/1.0"))
+	intensity, err := handler.GetCurrentIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, 263, intensity.Actual)
+	assert.Equal(t, "carbon-test/1.0", userAgent)
+
+	// Custom client
+	client := &http.Client{Timeout: 5 * time.Second}
+	handler = NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithHTTPClient(client))
+	assert.Equal(t, client, handler.client)
+	_, err = handler.GetCurrentIntensity()
+	assert.NoError(t, err)
+	assert.NotEqual(t, "carbon-test/1.0", userAgent)
+
+	// Custom transport, applied to a copy of the custom client
+	transport := &countingTransport{}
+	handler = NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithHTTPClient(client), WithTransport(transport))
+	_, err = handler.GetCurrentIntensity()
+	assert.NoError(t, err)
+	_, err = handler.GetCurrentIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, transport.requests)
+	assert.Equal(t, 5*time.Second, handler.client.Timeout)
+	assert.Nil(t, client.Transport)
+	assert.Nil(t, http.DefaultClient.Transport)
+
+	// A nil client is ignored, leaving the client to use with the transport
+	handler = NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithHTTPClient(nil), WithTransport(transport))
+	_, err = handler.GetCurrentIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, transport.requests)
+}
@@ -0,0 +1,151 @@
+package carbonintensity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// An outward postcode is the part of a postcode before the space, e.g. "RG10" in "RG10 9AB"
+var (
+	outwardPostcodeRegexp = regexp.MustCompile(`^[A-Z]{1,2}[0-9][A-Z0-9]?$`)
+	inwardPostcodeRegexp  = regexp.MustCompile(`^[0-9][A-Z]{2}$`)
+)
+
+// PostcodeError is returned when a postcode is not valid for the postcode resources of the API.
+// This is either because it failed validation by NormalisePostcode, or because the API rejected it.
+//
+// When the API rejected the postcode, Err holds the error returned by the API.
+type PostcodeError struct {
+	Postcode string
+	Reason   string
+	Err      error
+}
+
+func (pe *PostcodeError) Error() string {
+	return fmt.Sprintf("Invalid postcode %q; %s", pe.Postcode, pe.Reason)
+}
+
+// Unwrap returns the error returned by the API, if any
+func (pe *PostcodeError) Unwrap() error {
+	return pe.Err
+}
+
+// NormalisePostcode returns the outward postcode in the form expected by the API, e.g. "rg10 " becomes "RG10"
+//
+// Surrounding whitespace is removed and letters are upper cased.
+// A full postcode (e.g. "RG10 9AB") is accepted and reduced to its outward part.
+// If postcode is not a valid outward postcode a *PostcodeError is returned.
+func NormalisePostcode(postcode string) (string, error) {
+	fields := strings.Fields(strings.ToUpper(postcode))
+
+	if len(fields) == 2 && inwardPostcodeRegexp.MatchString(fields[1]) {
+		fields = fields[:1]
+	}
+
+	if len(fields) != 1 || !outwardPostcodeRegexp.MatchString(fields[0]) {
+		return "", &PostcodeError{Postcode: postcode, Reason: "must be an outward postcode, e.g. RG10"}
+	}
+
+	return fields[0], nil
+}
+
+// getPostcodeResponse normalises postcode and returns the entries of the regional resource built from it by resourceFormat.
+// Bad Request and Not Found errors from the API are returned as a *PostcodeError, as they are due to the API rejecting the
+// postcode. Other errors (e.g. the server being unavailable) are returned unchanged.
+func (ah *APIHandler) getPostcodeResponse(ctx context.Context, postcode string, resourceFormat string, args ...interface{}) ([]*RegionalIntensity, error) {
+	normalised, err := NormalisePostcode(postcode)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ah.getRegionalResponse(ctx, fmt.Sprintf(resourceFormat, append(args, normalised)...))
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusNotFound) {
+		return nil, &PostcodeError{Postcode: normalised, Reason: apiErr.Message, Err: err}
+	}
+
+	// Entries are returned along with any other error, so that entries skipped by lenient decoding don't lose the rest
+	return entries, err
+}
+
+// GetIntensityForPostcode returns a RegionalIntensity object, for the region containing the outward postcode
+// given by postcode, for the current 30 minute settlement period
+//
+// postcode is normalised with NormalisePostcode, so "rg10 " and "RG10 9AB" are both accepted as "RG10"
+func (ah *APIHandler) GetIntensityForPostcode(postcode string) (*RegionalIntensity, error) {
+	return ah.GetIntensityForPostcodeContext(context.Background(), postcode)
+}
+
+// GetIntensityForPostcodeContext is the same as GetIntensityForPostcode, but the request is made with the context ctx
+func (ah *APIHandler) GetIntensityForPostcodeContext(ctx context.Context, postcode string) (*RegionalIntensity, error) {
+	entries, err := ah.getPostcodeResponse(ctx, postcode, "/regional/postcode/%s")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) != 1 {
+		return nil, fmt.Errorf("%w; unexpected number of entries (%d) for postcode %s", ErrUnexpectedResponse, len(entries), postcode)
+	}
+
+	return entries[0], nil
+}
+
+// GetIntensityBetweenForPostcode returns an array of RegionalIntensity objects, for all 30 minute settlement periods
+// between from and to, for the region containing the outward postcode given by postcode
+//
+// The maximum date range is limited to 14 days
+func (ah *APIHandler) GetIntensityBetweenForPostcode(from time.Time, to time.Time, postcode string) ([]*RegionalIntensity, error) {
+	return ah.GetIntensityBetweenForPostcodeContext(context.Background(), from, to, postcode)
+}
+
+// GetIntensityBetweenForPostcodeContext is the same as GetIntensityBetweenForPostcode, but the request is made with the context ctx
+func (ah *APIHandler) GetIntensityBetweenForPostcodeContext(ctx context.Context, from time.Time, to time.Time, postcode string) ([]*RegionalIntensity, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w; from (%s) must be strictly earlier than to (%s)", ErrInvalidRange, from.String(), to.String())
+	}
+
+	if to.Sub(from) > (time.Hour * 24 * 14) {
+		return nil, fmt.Errorf("%w; the maximum date range is limited to 14 days. From (%s) To (%s)", ErrRangeTooLarge, from.String(), to.String())
+	}
+
+	return ah.getPostcodeResponse(ctx, postcode, "/regional/intensity/%s/%s/postcode/%s", from.Format(natGridTimeFormat), to.Format(natGridTimeFormat))
+}
+
+// GetNext24HourIntensityForPostcode returns an array of RegionalIntensity objects, for all 30 minute settlement periods
+// between from and from+24h, for the region containing the outward postcode given by postcode
+func (ah *APIHandler) GetNext24HourIntensityForPostcode(from time.Time, postcode string) ([]*RegionalIntensity, error) {
+	return ah.GetNext24HourIntensityForPostcodeContext(context.Background(), from, postcode)
+}
+
+// GetNext24HourIntensityForPostcodeContext is the same as GetNext24HourIntensityForPostcode, but the request is made with the context ctx
+func (ah *APIHandler) GetNext24HourIntensityForPostcodeContext(ctx context.Context, from time.Time, postcode string) ([]*RegionalIntensity, error) {
+	return ah.getPostcodeResponse(ctx, postcode, "/regional/intensity/%s/fw24h/postcode/%s", from.Format(natGridTimeFormat))
+}
+
+// GetNext48HourIntensityForPostcode returns an array of RegionalIntensity objects, for all 30 minute settlement periods
+// between from and from+48h, for the region containing the outward postcode given by postcode
+func (ah *APIHandler) GetNext48HourIntensityForPostcode(from time.Time, postcode string) ([]*RegionalIntensity, error) {
+	return ah.GetNext48HourIntensityForPostcodeContext(context.Background(), from, postcode)
+}
+
+// GetNext48HourIntensityForPostcodeContext is the same as GetNext48HourIntensityForPostcode, but the request is made with the context ctx
+func (ah *APIHandler) GetNext48HourIntensityForPostcodeContext(ctx context.Context, from time.Time, postcode string) ([]*RegionalIntensity, error) {
+	return ah.getPostcodeResponse(ctx, postcode, "/regional/intensity/%s/fw48h/postcode/%s", from.Format(natGridTimeFormat))
+}
+
+// GetPrior24HourIntensityForPostcode returns an array of RegionalIntensity objects, for all 30 minute settlement periods
+// between from-24h and from, for the region containing the outward postcode given by postcode
+func (ah *APIHandler) GetPrior24HourIntensityForPostcode(from time.Time, postcode string) ([]*RegionalIntensity, error) {
+	return ah.GetPrior24HourIntensityForPostcodeContext(context.Background(), from, postcode)
+}
+
+// GetPrior24HourIntensityForPostcodeContext is the same as GetPrior24HourIntensityForPostcode, but the request is made with the context ctx
+func (ah *APIHandler) GetPrior24HourIntensityForPostcodeContext(ctx context.Context, from time.Time, postcode string) ([]*RegionalIntensity, error) {
+	return ah.getPostcodeResponse(ctx, postcode, "/regional/intensity/%s/pt24h/postcode/%s", from.Format(natGridTimeFormat))
+}
@@ -0,0 +1,143 @@
+package carbonintensity
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testPostcodeResponse = `{"data":[{"regionid":12,"dnoregion":"SSE South","shortname":"South England","postcode":"RG10","data":[
+		{"from":"2018-05-15T11:30Z","to":"2018-05-15T12:00Z","intensity":{"forecast":266,"index":"moderate"},
+			"generationmix":[{"fuel":"gas","perc":43.6},{"fuel":"wind","perc":12.3}]}]}]}`
+
+	testPostcodeRangeResponse = `{"data":{"regionid":12,"dnoregion":"SSE South","shortname":"South England","postcode":"RG10","data":[
+		{"from":"2018-05-15T11:30Z","to":"2018-05-15T12:00Z","intensity":{"forecast":266,"index":"moderate"},"generationmix":[]},
+		{"from":"2018-05-15T12:00Z","to":"2018-05-15T12:30Z","intensity":{"forecast":250,"index":"moderate"},"generationmix":[]},
+		{"from":"2018-05-15T12:30Z","to":"2018-05-15T13:00Z","intensity":{"forecast":180,"index":"low"},"generationmix":[]}]}}`
+
+	testPostcodeErrorResponse = `{"error":{"code":"400 Bad Request","message":"Please enter a valid outward postcode"}}`
+)
+
+func TestNormalisePostcode(t *testing.T) {
+	for input, expected := range map[string]string{
+		"RG10":     "RG10",
+		"rg10 ":    "RG10",
+		" Rg10\t":  "RG10",
+		"RG10 9AB": "RG10",
+		"sw1a":     "SW1A",
+		"m1":       "M1",
+		"EC1A 1BB": "EC1A",
+	} {
+		normalised, err := NormalisePostcode(input)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, normalised)
+	}
+
+	for _, input := range []string{"", "  ", "RG", "10RG", "RG10 9", "RG10 9AB X", "RG1000", "RG10/../regional"} {
+		_, err := NormalisePostcode(input)
+		assert.Error(t, err)
+
+		var postcodeErr *PostcodeError
+		assert.True(t, errors.As(err, &postcodeErr))
+		assert.Equal(t, input, postcodeErr.Postcode)
+	}
+}
+
+func TestIntensityForPostcode(t *testing.T) {
+	handler, closeServer := newTestAPIHandler(t, map[string]string{
+		"/regional/postcode/RG10": testPostcodeResponse,
+		"/regional/postcode/ZZ99": testPostcodeErrorResponse,
+	})
+	defer closeServer()
+
+	regional, err := handler.GetIntensityForPostcode("rg10 ")
+	assert.NoError(t, err)
+	assert.Equal(t, 12, regional.RegionID)
+	assert.Equal(t, "South England", regional.ShortName)
+	assert.Equal(t, "RG10", regional.Postcode)
+	assert.Equal(t, 266, regional.Intensity.Forecast)
+	assert.Equal(t, 43.6, regional.GenerationMix.Gas)
+
+	// Rejected by the API, should return a PostcodeError
+	regional, err = handler.GetIntensityForPostcode("zz99")
+	assert.Error(t, err)
+
+	var postcodeErr *PostcodeError
+	assert.True(t, errors.As(err, &postcodeErr))
+	assert.Equal(t, "ZZ99", postcodeErr.Postcode)
+	assert.Equal(t, "Please enter a valid outward postcode", postcodeErr.Reason)
+	assert.Error(t, postcodeErr.Unwrap())
+
+	// Rejected by validation, should return a PostcodeError without making a request
+	regional, err = handler.GetIntensityForPostcode("not a postcode")
+	assert.Error(t, err)
+	assert.True(t, errors.As(err, &postcodeErr))
+	assert.Nil(t, postcodeErr.Unwrap())
+}
+
+func TestPostcodeServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":{"code":"503 Service Unavailable","message":"Service Unavailable"}}`))
+	}))
+	defer server.Close()
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL))
+
+	// The server failing isn't the postcode's fault, so should be returned as an APIError rather than a PostcodeError
+	_, err := handler.GetIntensityForPostcode("RG10")
+	assert.Error(t, err)
+
+	var postcodeErr *PostcodeError
+	assert.False(t, errors.As(err, &postcodeErr))
+
+	var apiErr *APIError
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	}
+}
+
+func TestPostcodeTimeRangeIntensity(t *testing.T) {
+	from := time.Date(2018, 5, 15, 11, 30, 0, 0, time.UTC)
+	to := time.Date(2018, 5, 15, 13, 0, 0, 0, time.UTC)
+
+	handler, closeServer := newTestAPIHandler(t, map[string]string{
+		"/regional/intensity/2018-05-15T11:30Z/2018-05-15T13:00Z/postcode/RG10": testPostcodeRangeResponse,
+		"/regional/intensity/2018-05-15T11:30Z/fw24h/postcode/RG10":             testPostcodeRangeResponse,
+		"/regional/intensity/2018-05-15T11:30Z/fw48h/postcode/RG10":             testPostcodeRangeResponse,
+		"/regional/intensity/2018-05-15T11:30Z/pt24h/postcode/RG10":             testPostcodeRangeResponse,
+	})
+	defer closeServer()
+
+	checkRegionalArr := func(regionalArr []*RegionalIntensity, err error) {
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(regionalArr))
+
+		for i, regional := range regionalArr {
+			assert.Equal(t, 12, regional.RegionID)
+			assert.Equal(t, "RG10", regional.Postcode)
+			assert.Equal(t, from.Add(time.Duration(i)*30*time.Minute), regional.Intensity.From)
+		}
+		assert.Equal(t, 180, regionalArr[2].Intensity.Forecast)
+		assert.Equal(t, IndexLow, regionalArr[2].Intensity.Index)
+	}
+
+	checkRegionalArr(handler.GetIntensityBetweenForPostcode(from, to, "RG10"))
+	checkRegionalArr(handler.GetNext24HourIntensityForPostcode(from, "rg10"))
+	checkRegionalArr(handler.GetNext48HourIntensityForPostcode(from, "RG10 9AB"))
+	checkRegionalArr(handler.GetPrior24HourIntensityForPostcode(from, " RG10"))
+
+	// to and from equal, should return error
+	_, err := handler.GetIntensityBetweenForPostcode(from, from, "RG10")
+	assert.Error(t, err)
+
+	// > 14 day period, should return error
+	_, err = handler.GetIntensityBetweenForPostcode(from, from.Add(15*24*time.Hour), "RG10")
+	assert.Error(t, err)
+}
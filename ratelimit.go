@@ -0,0 +1,125 @@
+package carbonintensity
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket rate limiter for requests to the API, see WithRateLimit and WithRateLimiter
+//
+// The bucket holds at most burst tokens and refills at requestsPerSecond tokens per second; each request takes one token.
+// A RateLimiter is safe for concurrent use, so can be shared by many goroutines and many APIHandlers.
+type RateLimiter struct {
+	mu                sync.Mutex
+	requestsPerSecond float64
+	burst             float64
+	tokens            float64
+	last              time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing requestsPerSecond requests per second on average,
+// with bursts of up to burst requests (at least 1). The bucket starts full.
+//
+// If requestsPerSecond isn't positive and finite requests aren't limited at all.
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	if !(requestsPerSecond > 0) {
+		requestsPerSecond = math.Inf(1)
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		requestsPerSecond: requestsPerSecond,
+		burst:             float64(burst),
+		tokens:            float64(burst),
+		last:              time.Now(),
+	}
+}
+
+// WithRateLimit makes the APIHandler limit its requests to requestsPerSecond requests per second on average,
+// with bursts of up to burst requests, as NewRateLimiter does
+//
+// Every attempt at a request counts, including retries.
+func WithRateLimit(requestsPerSecond float64, burst int) Option {
+	return WithRateLimiter(NewRateLimiter(requestsPerSecond, burst))
+}
+
+// WithRateLimiter makes the APIHandler limit its requests using limiter, which may be shared with other APIHandlers
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(ah *APIHandler) {
+		ah.rateLimiter = limiter
+	}
+}
+
+// reserve takes a token from the bucket, returning how long the caller must wait before the token can be used
+func (rl *RateLimiter) reserve(now time.Time) time.Duration {
+	if math.IsInf(rl.requestsPerSecond, 1) {
+		return 0
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if elapsed := now.Sub(rl.last); elapsed > 0 {
+		rl.tokens += elapsed.Seconds() * rl.requestsPerSecond
+		if rl.tokens > rl.burst {
+			rl.tokens = rl.burst
+		}
+		rl.last = now
+	}
+
+	// Tokens may go negative, which queues up callers behind each other
+	rl.tokens--
+	if rl.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-rl.tokens / rl.requestsPerSecond * float64(time.Second))
+}
+
+// unreserve returns a token taken by reserve which wasn't used
+func (rl *RateLimiter) unreserve() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.tokens++
+	if rl.tokens > rl.burst {
+		rl.tokens = rl.burst
+	}
+}
+
+// Wait blocks until a request may be made
+//
+// If ctx is done first its error is returned. If ctx has a deadline which is sooner than a request may be made
+// context.DeadlineExceeded is returned straight away, rather than waiting for the deadline to pass.
+func (rl *RateLimiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	delay := rl.reserve(now)
+	if delay == 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && deadline.Sub(now) < delay {
+		rl.unreserve()
+		return context.DeadlineExceeded
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		rl.unreserve()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
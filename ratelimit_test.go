@@ -0,0 +1,109 @@
+package carbonintensity
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	limiter := NewRateLimiter(10, 2)
+	now := limiter.last
+
+	// The bucket starts full
+	assert.Equal(t, time.Duration(0), limiter.reserve(now))
+	assert.Equal(t, time.Duration(0), limiter.reserve(now))
+
+	// Then callers queue up behind each other
+	assert.Equal(t, 100*time.Millisecond, limiter.reserve(now))
+	assert.Equal(t, 200*time.Millisecond, limiter.reserve(now))
+
+	// Unused reservations are returned
+	limiter.unreserve()
+	assert.Equal(t, 200*time.Millisecond, limiter.reserve(now))
+
+	// Refills over time, up to burst
+	assert.Equal(t, time.Duration(0), limiter.reserve(now.Add(time.Hour)))
+	assert.Equal(t, time.Duration(0), limiter.reserve(now.Add(time.Hour)))
+	assert.Equal(t, 100*time.Millisecond, limiter.reserve(now.Add(time.Hour)))
+}
+
+func TestNewRateLimiterInvalid(t *testing.T) {
+	now := time.Now()
+
+	// Rates which aren't positive and finite don't limit requests at all
+	for _, rate := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		limiter := NewRateLimiter(rate, 0)
+		for i := 0; i < 100; i++ {
+			assert.Equal(t, time.Duration(0), limiter.reserve(now))
+		}
+	}
+
+	// Bursts of less than 1 are 1
+	limiter := NewRateLimiter(10, 0)
+	assert.Equal(t, time.Duration(0), limiter.reserve(limiter.last))
+	assert.Equal(t, 100*time.Millisecond, limiter.reserve(limiter.last))
+
+	assert.NotPanics(t, func() { WithRateLimit(-1, -1) })
+}
+
+func TestRateLimit(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testCurrentIntensityResponse))
+	}))
+	defer server.Close()
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithRateLimit(50, 5))
+
+	// 5 requests are allowed straight away, the other 10 are spread over 200ms
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 15; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := handler.GetCurrentIntensity()
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(15), atomic.LoadInt32(&requests))
+	assert.True(t, time.Since(start) >= 180*time.Millisecond)
+}
+
+func TestRateLimitContext(t *testing.T) {
+	limiter := NewRateLimiter(1, 1)
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL("http://127.0.0.1:0"), WithRateLimiter(limiter), WithRetryPolicy(DefaultRetryPolicy))
+
+	assert.NoError(t, limiter.Wait(context.Background()))
+
+	// The deadline is sooner than the next token, so this should fail straight away without making a request
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := handler.GetCurrentIntensityContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < 100*time.Millisecond)
+
+	// Cancellation while waiting
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	err = limiter.Wait(ctx)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,189 @@
+package carbonintensity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// Region IDs 1 to 14 are the DNO regions, 15 to 17 are England, Scotland and Wales respectively
+	minRegionID = 1
+	maxRegionID = 17
+)
+
+type regionalResponse struct {
+	entryDecoder
+	entries []*RegionalIntensity
+}
+
+// RegionalIntensity represents a result from the 'regional carbon intensity' part of the API.
+// It represents the forecast carbon intensity and generation mix of a single region for a period of time.
+//
+// RegionID is the ID used by the API for the region, DNORegion is the name of the Distribution Network Operator
+// for the region and ShortName is a human readable name for the region (e.g. "North Scotland").
+// Postcode is only set for results from the postcode resources, and is the outward postcode that was queried.
+//
+// The regional part of the API only provides forecasts, so Intensity.Actual will always be set to -1.
+type RegionalIntensity struct {
+	RegionID      int            `json:"regionID"`
+	DNORegion     string         `json:"dnoRegion"`
+	ShortName     string         `json:"shortName"`
+	Postcode      string         `json:"postcode,omitempty"`
+	Intensity     *Intensity     `json:"intensity"`
+	GenerationMix *GenerationMix `json:"generationMix"`
+}
+
+// unmarshalRegionalPeriod builds a RegionalIntensity for a single region for a single period.
+// The intensity and generation mix are taken from values, which is the innermost of region and period.
+func unmarshalRegionalPeriod(region *apiRegion, period *apiPeriod, values *apiRegionalValues) (*RegionalIntensity, error) {
+	intensity, err := newIntensity(period, values.Intensity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RegionalIntensity{
+		RegionID:      region.RegionID,
+		DNORegion:     region.DNORegion,
+		ShortName:     region.ShortName,
+		Postcode:      region.Postcode,
+		Intensity:     intensity,
+		GenerationMix: unmarshalGenerationMix(values.GenerationMix, intensity.From, intensity.To),
+	}, nil
+}
+
+// The regional resources return data in one of two shapes;
+// either a list of periods each containing a list of regions ("regions"),
+// or a list of regions each containing a list of periods ("data").
+// In both cases the data is flattened to one RegionalIntensity per region per period.
+//
+// An entry with an invalid period or region nested within it is reported as an *EntryError, with Field locating
+// the invalid value (e.g. "data[3].from"). In lenient mode only the invalid period or region is skipped.
+func (rr *regionalResponse) UnmarshalJSON(data []byte) error {
+	return rr.decodeEntries(data, func(index int, rawEntry json.RawMessage) error {
+		var decodedEntry apiRegionalEntry
+		if err := json.Unmarshal(rawEntry, &decodedEntry); err != nil {
+			return err
+		}
+
+		if decodedEntry.Regions != nil {
+			for i := range decodedEntry.Regions {
+				region := &decodedEntry.Regions[i]
+				newEntry, err := unmarshalRegionalPeriod(region, &decodedEntry.apiPeriod, &region.apiRegionalValues)
+				if err := rr.check(index, fmt.Sprintf("regions[%d]", i), err); err != nil {
+					return err
+				}
+
+				if newEntry != nil {
+					rr.entries = append(rr.entries, newEntry)
+				}
+			}
+		} else if decodedEntry.Data != nil {
+			for i := range decodedEntry.Data {
+				period := &decodedEntry.Data[i]
+				newEntry, err := unmarshalRegionalPeriod(&decodedEntry.apiRegion, &period.apiPeriod, &period.apiRegionalValues)
+				if err := rr.check(index, fmt.Sprintf("data[%d]", i), err); err != nil {
+					return err
+				}
+
+				if newEntry != nil {
+					rr.entries = append(rr.entries, newEntry)
+				}
+			}
+		} else {
+			return errors.New("neither regions nor data")
+		}
+
+		return nil
+	})
+}
+
+func (ri *RegionalIntensity) String() string {
+	return fmt.Sprintf("%d %s (%s) %v", ri.RegionID, ri.ShortName, ri.DNORegion, ri.Intensity)
+}
+
+func (ah *APIHandler) getRegionalResponse(ctx context.Context, resource string) ([]*RegionalIntensity, error) {
+	responseBytes, err := ah.getAPIResponse(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	response := regionalResponse{entryDecoder: entryDecoder{lenient: ah.lenientDecoding}}
+	if err := unmarshalAPIResponse(responseBytes, &response); err != nil {
+		return nil, err
+	}
+
+	return response.entries, response.err()
+}
+
+func (ah *APIHandler) getSingleRegionalResponse(ctx context.Context, resource string) (*RegionalIntensity, error) {
+	entries, err := ah.getRegionalResponse(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) != 1 {
+		return nil, fmt.Errorf("%w; unexpected number of entries (%d) for %s", ErrUnexpectedResponse, len(entries), resource)
+	}
+
+	return entries[0], nil
+}
+
+// GetRegionalIntensity returns an array of RegionalIntensity objects, one for each region, for the current 30 minute settlement period
+//
+// This includes the DNO regions (region IDs 1 to 14) as well as England, Scotland and Wales (region IDs 15 to 17)
+func (ah *APIHandler) GetRegionalIntensity() ([]*RegionalIntensity, error) {
+	return ah.GetRegionalIntensityContext(context.Background())
+}
+
+// GetRegionalIntensityContext is the same as GetRegionalIntensity, but the request is made with the context ctx
+func (ah *APIHandler) GetRegionalIntensityContext(ctx context.Context) ([]*RegionalIntensity, error) {
+	return ah.getRegionalResponse(ctx, "/regional")
+}
+
+// GetEnglandIntensity returns a RegionalIntensity object for England, for the current 30 minute settlement period
+func (ah *APIHandler) GetEnglandIntensity() (*RegionalIntensity, error) {
+	return ah.GetEnglandIntensityContext(context.Background())
+}
+
+// GetEnglandIntensityContext is the same as GetEnglandIntensity, but the request is made with the context ctx
+func (ah *APIHandler) GetEnglandIntensityContext(ctx context.Context) (*RegionalIntensity, error) {
+	return ah.getSingleRegionalResponse(ctx, "/regional/england")
+}
+
+// GetScotlandIntensity returns a RegionalIntensity object for Scotland, for the current 30 minute settlement period
+func (ah *APIHandler) GetScotlandIntensity() (*RegionalIntensity, error) {
+	return ah.GetScotlandIntensityContext(context.Background())
+}
+
+// GetScotlandIntensityContext is the same as GetScotlandIntensity, but the request is made with the context ctx
+func (ah *APIHandler) GetScotlandIntensityContext(ctx context.Context) (*RegionalIntensity, error) {
+	return ah.getSingleRegionalResponse(ctx, "/regional/scotland")
+}
+
+// GetWalesIntensity returns a RegionalIntensity object for Wales, for the current 30 minute settlement period
+func (ah *APIHandler) GetWalesIntensity() (*RegionalIntensity, error) {
+	return ah.GetWalesIntensityContext(context.Background())
+}
+
+// GetWalesIntensityContext is the same as GetWalesIntensity, but the request is made with the context ctx
+func (ah *APIHandler) GetWalesIntensityContext(ctx context.Context) (*RegionalIntensity, error) {
+	return ah.getSingleRegionalResponse(ctx, "/regional/wales")
+}
+
+// GetIntensityForRegion returns a RegionalIntensity object for the region given by regionID, for the current 30 minute settlement period
+//
+// Region IDs are 1-indexed (numbered 1 to 17 inclusive), see https://carbon-intensity.github.io/api-definitions/#region-list
+func (ah *APIHandler) GetIntensityForRegion(regionID int) (*RegionalIntensity, error) {
+	return ah.GetIntensityForRegionContext(context.Background(), regionID)
+}
+
+// GetIntensityForRegionContext is the same as GetIntensityForRegion, but the request is made with the context ctx
+func (ah *APIHandler) GetIntensityForRegionContext(ctx context.Context, regionID int) (*RegionalIntensity, error) {
+	if regionID < minRegionID || regionID > maxRegionID {
+		return nil, fmt.Errorf("%w %d; must be %d <= regionID <= %d", ErrInvalidRegionID, regionID, minRegionID, maxRegionID)
+	}
+
+	return ah.getSingleRegionalResponse(ctx, fmt.Sprintf("/regional/regionid/%d", regionID))
+}
@@ -0,0 +1,112 @@
+package carbonintensity
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testRegionalAllResponse = `{"data":[{"from":"2018-05-15T11:30Z","to":"2018-05-15T12:00Z","regions":[
+		{"regionid":1,"dnoregion":"Scottish Hydro Electric Power Distribution","shortname":"North Scotland",
+			"intensity":{"forecast":0,"index":"very low"},
+			"generationmix":[{"fuel":"gas","perc":0},{"fuel":"hydro","perc":2.2},{"fuel":"wind","perc":97.8}]},
+		{"regionid":7,"dnoregion":"WPD South Wales","shortname":"South Wales",
+			"intensity":{"forecast":354,"index":"very high"},
+			"generationmix":[{"fuel":"gas","perc":78.5},{"fuel":"coal","perc":6.1},{"fuel":"wind","perc":15.4}]}]}]}`
+
+	testRegionalSingleResponse = `{"data":[{"regionid":%d,"dnoregion":"%s","shortname":"%s","data":[
+		{"from":"2018-05-15T11:30Z","to":"2018-05-15T12:00Z","intensity":{"forecast":252,"index":"moderate"},
+			"generationmix":[{"fuel":"biomass","perc":2.1},{"fuel":"nuclear","perc":20.5},{"fuel":"solar","perc":9.9},{"fuel":"storage","perc":0.5}]}]}]}`
+)
+
+func TestRegionalIntensity(t *testing.T) {
+	handler, closeServer := newTestAPIHandler(t, map[string]string{
+		"/regional": testRegionalAllResponse,
+	})
+	defer closeServer()
+
+	regionalArr, err := handler.GetRegionalIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(regionalArr))
+
+	assert.Equal(t, 1, regionalArr[0].RegionID)
+	assert.Equal(t, "Scottish Hydro Electric Power Distribution", regionalArr[0].DNORegion)
+	assert.Equal(t, "North Scotland", regionalArr[0].ShortName)
+	assert.Equal(t, 0, regionalArr[0].Intensity.Forecast)
+	assert.Equal(t, -1, regionalArr[0].Intensity.Actual)
+	assert.Equal(t, IndexVeryLow, regionalArr[0].Intensity.Index)
+	assert.Equal(t, 97.8, regionalArr[0].GenerationMix.Wind)
+	assert.Equal(t, 2.2, regionalArr[0].GenerationMix.Hydro)
+
+	assert.Equal(t, 7, regionalArr[1].RegionID)
+	assert.Equal(t, 354, regionalArr[1].Intensity.Forecast)
+	assert.Equal(t, 78.5, regionalArr[1].GenerationMix.Gas)
+	assert.Equal(t, 6.1, regionalArr[1].GenerationMix.Coal)
+
+	for _, regional := range regionalArr {
+		assert.Equal(t, time.Date(2018, 5, 15, 11, 30, 0, 0, time.UTC), regional.Intensity.From)
+		assert.Equal(t, time.Date(2018, 5, 15, 12, 0, 0, 0, time.UTC), regional.Intensity.To)
+		assert.Equal(t, regional.Intensity.From, regional.GenerationMix.From)
+		assert.Equal(t, regional.Intensity.To, regional.GenerationMix.To)
+		t.Logf("%v\n", regional)
+		t.Logf("%v\n", regional.GenerationMix)
+	}
+}
+
+func TestCountryIntensity(t *testing.T) {
+	handler, closeServer := newTestAPIHandler(t, map[string]string{
+		"/regional/england":  fmt.Sprintf(testRegionalSingleResponse, 15, "England", "England"),
+		"/regional/scotland": fmt.Sprintf(testRegionalSingleResponse, 16, "Scotland", "Scotland"),
+		"/regional/wales":    fmt.Sprintf(testRegionalSingleResponse, 17, "Wales", "Wales"),
+	})
+	defer closeServer()
+
+	regional, err := handler.GetEnglandIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, 15, regional.RegionID)
+	assert.Equal(t, "England", regional.ShortName)
+	assert.Equal(t, 252, regional.Intensity.Forecast)
+	assert.Equal(t, IndexModerate, regional.Intensity.Index)
+	assert.Equal(t, 2.1, regional.GenerationMix.Biomass)
+	assert.Equal(t, 20.5, regional.GenerationMix.Nuclear)
+	assert.Equal(t, 9.9, regional.GenerationMix.Solar)
+	assert.Equal(t, 0.5, regional.GenerationMix.Storage)
+
+	regional, err = handler.GetScotlandIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, 16, regional.RegionID)
+	assert.Equal(t, "Scotland", regional.ShortName)
+
+	regional, err = handler.GetWalesIntensity()
+	assert.NoError(t, err)
+	assert.Equal(t, 17, regional.RegionID)
+	assert.Equal(t, "Wales", regional.ShortName)
+}
+
+func TestIntensityForRegion(t *testing.T) {
+	handler, closeServer := newTestAPIHandler(t, map[string]string{
+		"/regional/regionid/7": fmt.Sprintf(testRegionalSingleResponse, 7, "WPD South Wales", "South Wales"),
+		"/regional/regionid/3": `{"error":{"code":"400 Bad Request","message":"Please enter a valid region ID"}}`,
+	})
+	defer closeServer()
+
+	regional, err := handler.GetIntensityForRegion(7)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, regional.RegionID)
+	assert.Equal(t, "WPD South Wales", regional.DNORegion)
+	assert.Equal(t, "South Wales", regional.ShortName)
+
+	// API errors should be returned
+	regional, err = handler.GetIntensityForRegion(3)
+	assert.Error(t, err)
+
+	// Out of range region IDs, should return error
+	regional, err = handler.GetIntensityForRegion(0)
+	assert.Error(t, err)
+
+	regional, err = handler.GetIntensityForRegion(18)
+	assert.Error(t, err)
+}
@@ -0,0 +1,145 @@
+package carbonintensity
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how requests to the API which fail are retried, see WithRetryPolicy
+//
+// MaxAttempts is the total number of attempts made for a request, including the first. Values less than 2 disable retries.
+//
+// The delay before the first retry is BaseDelay, doubling for each further retry up to a maximum of MaxDelay
+// (if MaxDelay is 0 there is no maximum).
+// Jitter (between 0 and 1) is the fraction of each delay which is randomised, to avoid many clients retrying in lockstep;
+// 0 gives exact delays, 1 gives delays anywhere between 0 and the full delay.
+//
+// If a response has a Retry-After header the delay is at least as long as it asks for.
+// If it asks for longer than MaxDelay (when set) the error is returned rather than retried.
+//
+// Which errors are retried is decided by Retryable if it is set. Otherwise an *APIError is retried if its StatusCode
+// is in RetryableStatusCodes, and errors making the request (e.g. network failures and timeouts) are always retried.
+// Errors from bad arguments, unexpected responses and cancellation of the request's context are never retried.
+type RetryPolicy struct {
+	MaxAttempts          int
+	BaseDelay            time.Duration
+	MaxDelay             time.Duration
+	Jitter               float64
+	RetryableStatusCodes []int
+	Retryable            func(err error) bool
+}
+
+// DefaultRetryPolicy is a reasonable RetryPolicy for use with the national grid carbon intensity API server
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+	Jitter:      0.5,
+	RetryableStatusCodes: []int{
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+// WithRetryPolicy makes the APIHandler retry failed requests as allowed by policy
+//
+// By default an APIHandler makes a single attempt at each request.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(ah *APIHandler) {
+		ah.retryPolicy = policy
+	}
+}
+
+// isRetryable returns whether err, returned by an attempt at a request with the context ctx, should be retried
+func (rp *RetryPolicy) isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if rp.Retryable != nil {
+		return rp.Retryable(err)
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		for _, statusCode := range rp.RetryableStatusCodes {
+			if apiErr.StatusCode == statusCode {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return !errors.Is(err, ErrUnexpectedResponse)
+}
+
+// retryDelay returns how long to wait before retrying after attempt number attempt failed with err,
+// or false if the request shouldn't be retried
+func (rp *RetryPolicy) retryDelay(ctx context.Context, attempt int, err error) (time.Duration, bool) {
+	if attempt >= rp.MaxAttempts || !rp.isRetryable(ctx, err) {
+		return 0, false
+	}
+
+	// A MaxDelay of 0 means there is no maximum, though doubling stops before it would overflow
+	capped := rp.MaxDelay > 0
+	delay := rp.BaseDelay
+	for i := 1; i < attempt && (!capped || delay < rp.MaxDelay) && delay <= math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+
+	if capped && delay > rp.MaxDelay {
+		delay = rp.MaxDelay
+	}
+
+	if rp.Jitter > 0 {
+		jitter := time.Duration(rp.Jitter * float64(delay))
+		if jitter > delay {
+			jitter = delay
+		}
+		delay -= time.Duration(rand.Int63n(int64(jitter) + 1))
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > delay {
+		if capped && apiErr.RetryAfter > rp.MaxDelay {
+			return 0, false
+		}
+
+		delay = apiErr.RetryAfter
+	}
+
+	return delay, true
+}
+
+// parseRetryAfter returns the duration asked for by the value of a Retry-After header, which is either
+// a number of seconds or an HTTP date. Missing or invalid values give 0.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,179 @@
+package carbonintensity
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// retryTestFrom is the start of the hour the retry tests ask for
+var retryTestFrom = time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC)
+
+// newFlakyTestServer returns a test server which fails the first failures requests with statusCode, then succeeds
+func newFlakyTestServer(failures int, statusCode int, retryAfter string) *testPeriodServer {
+	return startTestPeriodServer(&testPeriodServer{failures: failures, failureStatus: statusCode, retryAfter: retryAfter})
+}
+
+func TestRetry(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:          3,
+		BaseDelay:            time.Millisecond,
+		MaxDelay:             10 * time.Millisecond,
+		RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+	}
+
+	// Retried until success
+	server := newFlakyTestServer(2, http.StatusServiceUnavailable, "")
+	defer server.Close()
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithRetryPolicy(policy))
+	intensityArr, err := handler.GetIntensityBetween(retryTestFrom, retryTestFrom.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(intensityArr))
+	assert.Equal(t, 3, server.requestCount())
+
+	// Gives up after MaxAttempts
+	server = newFlakyTestServer(3, http.StatusServiceUnavailable, "")
+	defer server.Close()
+
+	handler = NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithRetryPolicy(policy))
+	_, err = handler.GetIntensityBetween(retryTestFrom, retryTestFrom.Add(time.Hour))
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, 3, server.requestCount())
+
+	// Status codes which aren't retryable are returned straight away
+	server = newFlakyTestServer(1, http.StatusBadRequest, "")
+	defer server.Close()
+
+	handler = NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithRetryPolicy(policy))
+	_, err = handler.GetIntensityBetween(retryTestFrom, retryTestFrom.Add(time.Hour))
+	assert.Error(t, err)
+	assert.Equal(t, 1, server.requestCount())
+
+	// No retries by default
+	server = newFlakyTestServer(1, http.StatusServiceUnavailable, "")
+	defer server.Close()
+
+	handler = NewCarbonIntensityAPIHandler(WithBaseURL(server.URL))
+	_, err = handler.GetIntensityBetween(retryTestFrom, retryTestFrom.Add(time.Hour))
+	assert.Error(t, err)
+	assert.Equal(t, 1, server.requestCount())
+
+	// Custom Retryable
+	server = newFlakyTestServer(1, http.StatusBadRequest, "")
+	defer server.Close()
+
+	customPolicy := policy
+	customPolicy.Retryable = func(err error) bool { return true }
+	handler = NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithRetryPolicy(customPolicy))
+	_, err = handler.GetIntensityBetween(retryTestFrom, retryTestFrom.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, server.requestCount())
+}
+
+func TestRetryAfter(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:          2,
+		BaseDelay:            time.Millisecond,
+		MaxDelay:             5 * time.Second,
+		RetryableStatusCodes: []int{http.StatusTooManyRequests},
+	}
+
+	server := newFlakyTestServer(1, http.StatusTooManyRequests, "1")
+	defer server.Close()
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithRetryPolicy(policy))
+	start := time.Now()
+	_, err := handler.GetIntensityBetween(retryTestFrom, retryTestFrom.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= time.Second)
+	assert.Equal(t, 2, server.requestCount())
+
+	// Asking for longer than MaxDelay gives up
+	server = newFlakyTestServer(1, http.StatusTooManyRequests, "60")
+	defer server.Close()
+
+	handler = NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithRetryPolicy(policy))
+	_, err = handler.GetIntensityBetween(retryTestFrom, retryTestFrom.Add(time.Hour))
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, time.Minute, apiErr.RetryAfter)
+	assert.Equal(t, 1, server.requestCount())
+}
+
+func TestRetryContextCancellation(t *testing.T) {
+	server := newFlakyTestServer(10, http.StatusServiceUnavailable, "")
+	defer server.Close()
+
+	policy := DefaultRetryPolicy
+	policy.BaseDelay = time.Hour
+	policy.MaxDelay = time.Hour
+
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL), WithRetryPolicy(policy))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := handler.GetIntensityBetweenContext(ctx, retryTestFrom, retryTestFrom.Add(time.Hour))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 1, server.requestCount())
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:          10,
+		BaseDelay:            100 * time.Millisecond,
+		MaxDelay:             time.Second,
+		RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+	}
+	ctx := context.Background()
+	err := &APIError{StatusCode: http.StatusServiceUnavailable}
+
+	for attempt, expected := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		delay, retry := policy.retryDelay(ctx, attempt+1, err)
+		assert.True(t, retry)
+		assert.Equal(t, expected*time.Millisecond, delay)
+	}
+
+	_, retry := policy.retryDelay(ctx, 10, err)
+	assert.False(t, retry)
+
+	_, retry = policy.retryDelay(ctx, 1, ErrUnexpectedResponse)
+	assert.False(t, retry)
+
+	_, retry = policy.retryDelay(ctx, 1, errors.New("connection reset by peer"))
+	assert.True(t, retry)
+
+	policy.Jitter = 0.5
+	for attempt := 1; attempt < 10; attempt++ {
+		delay, _ := policy.retryDelay(ctx, attempt, err)
+		assert.True(t, delay >= 50*time.Millisecond)
+		assert.True(t, delay <= time.Second)
+	}
+
+	// Without a MaxDelay the delay keeps doubling, and any Retry-After is waited for
+	policy = RetryPolicy{MaxAttempts: 100, BaseDelay: time.Second, RetryableStatusCodes: []int{http.StatusServiceUnavailable}}
+	for attempt, expected := range []time.Duration{1, 2, 4, 8, 16} {
+		delay, retry := policy.retryDelay(ctx, attempt+1, err)
+		assert.True(t, retry)
+		assert.Equal(t, expected*time.Second, delay)
+	}
+
+	delay, retry := policy.retryDelay(ctx, 99, err)
+	assert.True(t, retry)
+	assert.True(t, delay > 0)
+
+	delay, retry = policy.retryDelay(ctx, 1, &APIError{StatusCode: http.StatusServiceUnavailable, RetryAfter: time.Hour})
+	assert.True(t, retry)
+	assert.Equal(t, time.Hour, delay)
+
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(""))
+	assert.InDelta(t, float64(time.Hour), float64(parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))), float64(2*time.Second))
+}
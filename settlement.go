@@ -0,0 +1,117 @@
+package carbonintensity
+
+import (
+	"fmt"
+	"time"
+	// Settlement days follow UK time, whether or not the system has the time zone database
+	_ "time/tzdata"
+)
+
+const settlementPeriodDuration = 30 * time.Minute
+
+// ukTime is the time zone settlement days follow
+var ukTime = mustLoadLocation("Europe/London")
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return location
+}
+
+// SettlementPeriod is one of the half-hour periods national grid split each day into, given by the day and its number
+// within the day (Period).
+//
+// Days follow UK local time, so start at midnight GMT in winter and midnight BST (23:00 UTC) in summer.
+// Periods are 1-indexed; most days have 48, but the days the clocks go forward have 46, and the days they go back have 50.
+type SettlementPeriod struct {
+	Year   int
+	Month  time.Month
+	Day    int
+	Period int
+}
+
+// NewSettlementPeriod returns the SettlementPeriod numbered period in the day represented by date.
+//
+// An error wrapping ErrInvalidSettlementPeriod is returned if the day doesn't have a settlement period numbered period.
+func NewSettlementPeriod(date time.Time, period int) (SettlementPeriod, error) {
+	year, month, day := date.Date()
+	sp := SettlementPeriod{Year: year, Month: month, Day: day, Period: period}
+
+	return sp, sp.Validate()
+}
+
+// SettlementPeriodForTime returns the SettlementPeriod containing t
+func SettlementPeriodForTime(t time.Time) SettlementPeriod {
+	year, month, day := t.In(ukTime).Date()
+	dayStart := time.Date(year, month, day, 0, 0, 0, 0, ukTime)
+
+	return SettlementPeriod{Year: year, Month: month, Day: day, Period: int(t.Sub(dayStart)/settlementPeriodDuration) + 1}
+}
+
+// SettlementPeriodsInDay returns the number of settlement periods in the day represented by date; 48, or 46 or 50 if the
+// clocks change that day
+func SettlementPeriodsInDay(date time.Time) int {
+	year, month, day := date.Date()
+	dayStart := time.Date(year, month, day, 0, 0, 0, 0, ukTime)
+
+	return int(dayStart.AddDate(0, 0, 1).Sub(dayStart) / settlementPeriodDuration)
+}
+
+// SettlementPeriodsBetween returns every SettlementPeriod which is at least partly between from and to, in order
+func SettlementPeriodsBetween(from time.Time, to time.Time) []SettlementPeriod {
+	var periods []SettlementPeriod
+	for sp := SettlementPeriodForTime(from); sp.Start().Before(to); sp = sp.Next() {
+		periods = append(periods, sp)
+	}
+
+	return periods
+}
+
+// Date returns the start of the day of the settlement period, i.e. midnight UK time
+func (sp SettlementPeriod) Date() time.Time {
+	return time.Date(sp.Year, sp.Month, sp.Day, 0, 0, 0, 0, ukTime)
+}
+
+// Start returns the time the settlement period starts, in UTC
+func (sp SettlementPeriod) Start() time.Time {
+	return sp.Date().Add(time.Duration(sp.Period-1) * settlementPeriodDuration).UTC()
+}
+
+// End returns the time the settlement period ends (i.e. the start of the next), in UTC
+func (sp SettlementPeriod) End() time.Time {
+	return sp.Start().Add(settlementPeriodDuration)
+}
+
+// Next returns the settlement period following sp, which is the first of the next day if sp is the last of its day
+func (sp SettlementPeriod) Next() SettlementPeriod {
+	return SettlementPeriodForTime(sp.End())
+}
+
+// Previous returns the settlement period before sp, which is the last of the previous day if sp is the first of its day
+func (sp SettlementPeriod) Previous() SettlementPeriod {
+	return SettlementPeriodForTime(sp.Start().Add(-settlementPeriodDuration))
+}
+
+// Validate returns an error wrapping ErrInvalidSettlementPeriod if sp isn't a settlement period of its day,
+// and nil otherwise
+func (sp SettlementPeriod) Validate() error {
+	if year, month, day := sp.Date().Date(); year != sp.Year || month != sp.Month || day != sp.Day {
+		return fmt.Errorf("%w; %04d-%02d-%02d isn't a valid date", ErrInvalidSettlementPeriod, sp.Year, sp.Month, sp.Day)
+	}
+
+	periods := SettlementPeriodsInDay(sp.Date())
+	if sp.Period < 1 || sp.Period > periods {
+		return fmt.Errorf("%w %d; must be 1 <= settlementPeriod <= %d for %04d-%02d-%02d", ErrInvalidSettlementPeriod, sp.Period,
+			periods, sp.Year, sp.Month, sp.Day)
+	}
+
+	return nil
+}
+
+// String returns the settlement period as the API names it, e.g. 2018-01-20/3
+func (sp SettlementPeriod) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d/%d", sp.Year, sp.Month, sp.Day, sp.Period)
+}
@@ -0,0 +1,87 @@
+package carbonintensity
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettlementPeriodsInDay(t *testing.T) {
+	assert.Equal(t, 48, SettlementPeriodsInDay(time.Date(2018, 1, 20, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 48, SettlementPeriodsInDay(time.Date(2018, 6, 20, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 46, SettlementPeriodsInDay(time.Date(2018, 3, 25, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 50, SettlementPeriodsInDay(time.Date(2018, 10, 28, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestSettlementPeriodForTime(t *testing.T) {
+	for _, test := range []struct {
+		time     time.Time
+		expected SettlementPeriod
+	}{
+		{time.Date(2018, 1, 20, 0, 0, 0, 0, time.UTC), SettlementPeriod{2018, time.January, 20, 1}},
+		{time.Date(2018, 1, 20, 1, 29, 0, 0, time.UTC), SettlementPeriod{2018, time.January, 20, 3}},
+		{time.Date(2018, 1, 20, 23, 45, 0, 0, time.UTC), SettlementPeriod{2018, time.January, 20, 48}},
+		// In summer the day starts at 23:00 UTC
+		{time.Date(2018, 6, 19, 23, 0, 0, 0, time.UTC), SettlementPeriod{2018, time.June, 20, 1}},
+		{time.Date(2018, 6, 20, 22, 30, 0, 0, time.UTC), SettlementPeriod{2018, time.June, 20, 48}},
+		// After the clocks go forward at 01:00 UTC, there are two fewer periods before it
+		{time.Date(2018, 3, 25, 1, 0, 0, 0, time.UTC), SettlementPeriod{2018, time.March, 25, 3}},
+		{time.Date(2018, 3, 25, 22, 30, 0, 0, time.UTC), SettlementPeriod{2018, time.March, 25, 46}},
+		// After the clocks go back at 01:00 UTC, there are two more
+		{time.Date(2018, 10, 28, 1, 0, 0, 0, time.UTC), SettlementPeriod{2018, time.October, 28, 5}},
+		{time.Date(2018, 10, 28, 23, 30, 0, 0, time.UTC), SettlementPeriod{2018, time.October, 28, 50}},
+	} {
+		sp := SettlementPeriodForTime(test.time)
+		assert.Equal(t, test.expected, sp, "%s", test.time)
+		assert.Nil(t, sp.Validate(), "%s", test.time)
+		assert.Equal(t, test.time.Truncate(30*time.Minute), sp.Start(), "%s", test.time)
+		assert.Equal(t, sp.Start().Add(30*time.Minute), sp.End(), "%s", test.time)
+	}
+}
+
+func TestSettlementPeriodIteration(t *testing.T) {
+	last := SettlementPeriod{2018, time.October, 28, 50}
+	assert.Equal(t, SettlementPeriod{2018, time.October, 29, 1}, last.Next())
+	assert.Equal(t, last, last.Next().Previous())
+	assert.Equal(t, SettlementPeriod{2018, time.March, 24, 48}, SettlementPeriod{2018, time.March, 25, 1}.Previous())
+
+	// The whole of the day the clocks go forward, plus the periods either side
+	from := time.Date(2018, 3, 24, 23, 30, 0, 0, time.UTC)
+	periods := SettlementPeriodsBetween(from, time.Date(2018, 3, 25, 23, 1, 0, 0, time.UTC))
+	if assert.Equal(t, 48, len(periods)) {
+		assert.Equal(t, SettlementPeriod{2018, time.March, 24, 48}, periods[0])
+		assert.Equal(t, SettlementPeriod{2018, time.March, 25, 1}, periods[1])
+		assert.Equal(t, SettlementPeriod{2018, time.March, 25, 46}, periods[46])
+		assert.Equal(t, SettlementPeriod{2018, time.March, 26, 1}, periods[47])
+
+		for i, sp := range periods {
+			assert.Equal(t, from.Add(time.Duration(i)*30*time.Minute), sp.Start())
+		}
+	}
+
+	assert.Equal(t, 0, len(SettlementPeriodsBetween(from, from)))
+	assert.Equal(t, "2018-03-24/48", periods[0].String())
+}
+
+func TestSettlementPeriodValidation(t *testing.T) {
+	for date, periods := range map[time.Time]int{
+		time.Date(2018, 1, 20, 0, 0, 0, 0, time.UTC):   48,
+		time.Date(2018, 3, 25, 0, 0, 0, 0, time.UTC):   46,
+		time.Date(2018, 10, 28, 0, 0, 0, 0, time.UTC):  50,
+		time.Date(2018, 10, 28, 23, 0, 0, 0, time.UTC): 50,
+	} {
+		_, err := NewSettlementPeriod(date, periods)
+		assert.Nil(t, err, "%s", date)
+
+		_, err = NewSettlementPeriod(date, periods+1)
+		assert.True(t, errors.Is(err, ErrInvalidSettlementPeriod), "%s", date)
+
+		_, err = NewSettlementPeriod(date, 0)
+		assert.True(t, errors.Is(err, ErrInvalidSettlementPeriod), "%s", date)
+	}
+
+	err := SettlementPeriod{2018, time.February, 30, 1}.Validate()
+	assert.True(t, errors.Is(err, ErrInvalidSettlementPeriod))
+}
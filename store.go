@@ -0,0 +1,158 @@
+package carbonintensity
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store persists data fetched from the API, so that it needn't be fetched again; see CachingHandler
+//
+// Entries are identified by their From and To, so putting an entry replaces any stored with the same From and To.
+// GetIntensity and GetGenerationMix return the stored entries starting between from (inclusive) and to (exclusive).
+// GetStatistics returns the stored entries lying wholly between from and to. Entries are returned in order of From.
+//
+// Implementations must be safe for concurrent use.
+type Store interface {
+	PutIntensity(entries []*Intensity) error
+	GetIntensity(from time.Time, to time.Time) ([]*Intensity, error)
+	PutStatistics(entries []*Statistics) error
+	GetStatistics(from time.Time, to time.Time) ([]*Statistics, error)
+	PutGenerationMix(entries []*GenerationMix) error
+	GetGenerationMix(from time.Time, to time.Time) ([]*GenerationMix, error)
+}
+
+// storeKey identifies an entry in a Store
+type storeKey struct {
+	from time.Time
+	to   time.Time
+}
+
+func newStoreKey(from time.Time, to time.Time) storeKey {
+	return storeKey{from: from.UTC(), to: to.UTC()}
+}
+
+// MemoryStore is a Store which holds everything in memory
+type MemoryStore struct {
+	mutex      sync.Mutex
+	intensity  map[storeKey]*Intensity
+	statistics map[storeKey]*Statistics
+	generation map[storeKey]*GenerationMix
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		intensity:  make(map[storeKey]*Intensity),
+		statistics: make(map[storeKey]*Statistics),
+		generation: make(map[storeKey]*GenerationMix),
+	}
+}
+
+// PutIntensity implements Store, storing copies of entries
+func (ms *MemoryStore) PutIntensity(entries []*Intensity) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	for _, entry := range entries {
+		stored := *entry
+		ms.intensity[newStoreKey(entry.From, entry.To)] = &stored
+	}
+
+	return nil
+}
+
+// GetIntensity implements Store, returning copies of the stored entries
+func (ms *MemoryStore) GetIntensity(from time.Time, to time.Time) ([]*Intensity, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	var entries []*Intensity
+	for key, stored := range ms.intensity {
+		if !key.from.Before(from) && key.from.Before(to) {
+			entry := *stored
+			entries = append(entries, &entry)
+		}
+	}
+
+	sortIntensity(entries)
+	return entries, nil
+}
+
+// PutStatistics implements Store, storing copies of entries
+func (ms *MemoryStore) PutStatistics(entries []*Statistics) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	for _, entry := range entries {
+		stored := *entry
+		ms.statistics[newStoreKey(entry.From, entry.To)] = &stored
+	}
+
+	return nil
+}
+
+// GetStatistics implements Store, returning copies of the stored entries
+func (ms *MemoryStore) GetStatistics(from time.Time, to time.Time) ([]*Statistics, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	var entries []*Statistics
+	for key, stored := range ms.statistics {
+		if !key.from.Before(from) && !key.to.After(to) {
+			entry := *stored
+			entries = append(entries, &entry)
+		}
+	}
+
+	sortStatistics(entries)
+	return entries, nil
+}
+
+// PutGenerationMix implements Store, storing copies of entries
+func (ms *MemoryStore) PutGenerationMix(entries []*GenerationMix) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	for _, entry := range entries {
+		stored := *entry
+		ms.generation[newStoreKey(entry.From, entry.To)] = &stored
+	}
+
+	return nil
+}
+
+// GetGenerationMix implements Store, returning copies of the stored entries
+func (ms *MemoryStore) GetGenerationMix(from time.Time, to time.Time) ([]*GenerationMix, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	var entries []*GenerationMix
+	for key, stored := range ms.generation {
+		if !key.from.Before(from) && key.from.Before(to) {
+			entry := *stored
+			entries = append(entries, &entry)
+		}
+	}
+
+	sortGenerationMix(entries)
+	return entries, nil
+}
+
+func sortIntensity(entries []*Intensity) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].From.Before(entries[j].From) })
+}
+
+func sortStatistics(entries []*Statistics) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].From.Equal(entries[j].From) {
+			return entries[i].To.Before(entries[j].To)
+		}
+
+		return entries[i].From.Before(entries[j].From)
+	})
+}
+
+func sortGenerationMix(entries []*GenerationMix) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].From.Before(entries[j].From) })
+}
@@ -0,0 +1,189 @@
+package carbonintensity
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testStore checks the behaviour common to all Store implementations
+func testStore(t *testing.T, store Store) {
+	from := time.Date(2018, 1, 31, 23, 0, 0, 0, time.UTC)
+
+	var intensity []*Intensity
+	var generation []*GenerationMix
+	for period := 0; period < 4; period++ {
+		periodFrom := from.Add(time.Duration(period) * settlementPeriodDuration)
+		intensity = append(intensity, &Intensity{From: periodFrom, To: periodFrom.Add(settlementPeriodDuration), Forecast: 200 + period,
+			Actual: -1, Index: IndexModerate})
+		generation = append(generation, &GenerationMix{From: periodFrom, To: periodFrom.Add(settlementPeriodDuration), Wind: float64(period)})
+	}
+
+	// Spanning the end of January, in reverse order
+	assert.NoError(t, store.PutIntensity([]*Intensity{intensity[3], intensity[2], intensity[1], intensity[0]}))
+	assert.NoError(t, store.PutGenerationMix(generation))
+
+	// Replacing an entry, with a time in another zone
+	replacement := *intensity[1]
+	replacement.From = replacement.From.In(time.FixedZone("UTC+1", 60*60))
+	replacement.Actual = 190
+	assert.NoError(t, store.PutIntensity([]*Intensity{&replacement}))
+
+	stored, err := store.GetIntensity(from, from.Add(2*time.Hour))
+	assert.NoError(t, err)
+	if assert.Equal(t, 4, len(stored)) {
+		assert.True(t, stored[0].From.Equal(from))
+		assert.Equal(t, 200, stored[0].Forecast)
+		assert.Equal(t, -1, stored[0].Actual)
+		assert.Equal(t, 190, stored[1].Actual)
+		assert.Equal(t, IndexModerate, stored[1].Index)
+		assert.True(t, stored[3].From.Equal(from.Add(90*time.Minute)))
+	}
+
+	// Only entries starting in the range are returned
+	stored, err = store.GetIntensity(from.Add(time.Hour), from.Add(100*time.Minute))
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(stored)) {
+		assert.Equal(t, 202, stored[0].Forecast)
+		assert.Equal(t, 203, stored[1].Forecast)
+	}
+
+	stored, err = store.GetIntensity(from.Add(-time.Hour), from)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(stored))
+
+	mixes, err := store.GetGenerationMix(from.Add(30*time.Minute), from.Add(time.Hour))
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(mixes)) {
+		assert.Equal(t, 1.0, mixes[0].Wind)
+	}
+
+	// Statistics are only returned if they are wholly in the range
+	day := time.Date(2018, 1, 20, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, store.PutStatistics([]*Statistics{
+		{From: day, To: day.Add(24 * time.Hour), Max: 300, Average: 200, Min: 100, Index: IndexModerate},
+		{From: day, To: day.Add(12 * time.Hour), Max: 250, Average: 150, Min: -1, Index: IndexLow},
+	}))
+
+	statistics, err := store.GetStatistics(day, day.Add(24*time.Hour))
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(statistics)) {
+		assert.Equal(t, 250, statistics[0].Max)
+		assert.Equal(t, -1, statistics[0].Min)
+		assert.Equal(t, 300, statistics[1].Max)
+	}
+
+	statistics, err = store.GetStatistics(day, day.Add(23*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(statistics))
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	testStore(t, store)
+
+	// Entries are copied, so changing them doesn't change the store
+	from := time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC)
+	entry := &Intensity{From: from, To: from.Add(settlementPeriodDuration), Forecast: 200, Actual: 210}
+	assert.NoError(t, store.PutIntensity([]*Intensity{entry}))
+	entry.Actual = 0
+
+	stored, err := store.GetIntensity(from, entry.To)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(stored)) {
+		assert.Equal(t, 210, stored[0].Actual)
+		stored[0].Actual = 0
+	}
+
+	stored, err = store.GetIntensity(from, entry.To)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(stored)) {
+		assert.Equal(t, 210, stored[0].Actual)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileStore(filepath.Join(dir, "store"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	testStore(t, store)
+
+	// One file per kind per month
+	paths, err := filepath.Glob(filepath.Join(dir, "store", "*.jsonl"))
+	assert.NoError(t, err)
+	for i := range paths {
+		paths[i] = filepath.Base(paths[i])
+	}
+	assert.Equal(t, []string{"generation-2018-01.jsonl", "generation-2018-02.jsonl", "intensity-2018-01.jsonl",
+		"intensity-2018-02.jsonl", "statistics-2018-01.jsonl"}, paths)
+
+	// A new FileStore for the directory sees the same entries
+	store, err = NewFileStore(filepath.Join(dir, "store"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	from := time.Date(2018, 1, 31, 23, 0, 0, 0, time.UTC)
+	stored, err := store.GetIntensity(from, from.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(stored))
+
+	// An incomplete last line, as left by a crash while writing, is ignored and then replaced
+	path := filepath.Join(dir, "store", "intensity-2018-02.jsonl")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if !assert.NoError(t, err) {
+		return
+	}
+	file.WriteString(`{"from":"2018-02-01T00:00:00Z","to":"2018-02-01T00:30:00Z","forec`)
+	file.Close()
+
+	stored, err = store.GetIntensity(from, from.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(stored))
+
+	periodFrom := time.Date(2018, 2, 1, 1, 0, 0, 0, time.UTC)
+	assert.NoError(t, store.PutIntensity([]*Intensity{{From: periodFrom, To: periodFrom.Add(settlementPeriodDuration), Forecast: 220,
+		Actual: 230, Index: IndexHigh}}))
+
+	stored, err = store.GetIntensity(from, from.Add(3*time.Hour))
+	assert.NoError(t, err)
+	if assert.Equal(t, 5, len(stored)) {
+		assert.Equal(t, 230, stored[4].Actual)
+	}
+
+	// Compacting removes the replaced line, keeping the same entries
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "store", "intensity-2018-01.jsonl"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(contents), "\n"))
+
+	assert.NoError(t, store.Compact())
+
+	contents, err = ioutil.ReadFile(filepath.Join(dir, "store", "intensity-2018-01.jsonl"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(contents), "\n"))
+	assert.Contains(t, string(contents), `"actual":190`)
+
+	compacted, err := store.GetIntensity(from, from.Add(3*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, stored, compacted)
+
+	// Invalid lines are reported
+	assert.NoError(t, ioutil.WriteFile(path, []byte("{}\nnot json\n"), 0644))
+	_, err = store.GetIntensity(from, from.Add(3*time.Hour))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "line 2")
+	}
+}
@@ -0,0 +1,297 @@
+package carbonintensity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WatchEventType is the kind of change a WatchEvent reports
+type WatchEventType int
+
+// The valid values of WatchEventType
+const (
+	// EventNewPeriod is sent when a new settlement period starts, Intensity is that of the new period
+	EventNewPeriod WatchEventType = iota + 1
+	// EventActualPublished is sent when the Actual value of a settlement period which has ended is published
+	EventActualPublished
+	// EventForecastChanged is sent when the Forecast of an upcoming settlement period changes by more than
+	// WatchConfig.ForecastThreshold. Previous is the Intensity last sent for the period (or first seen, if none has been sent).
+	EventForecastChanged
+	// EventError is sent when polling the API fails, Err is the error. Polling continues after a backoff.
+	EventError
+)
+
+var watchEventTypeNames = map[WatchEventType]string{
+	EventNewPeriod:       "new period",
+	EventActualPublished: "actual published",
+	EventForecastChanged: "forecast changed",
+	EventError:           "error",
+}
+
+func (wet WatchEventType) String() string {
+	if name, ok := watchEventTypeNames[wet]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("WatchEventType(%d)", int(wet))
+}
+
+// WatchEvent is a change in the data from the API, sent by Watch
+type WatchEvent struct {
+	Type      WatchEventType
+	Intensity *Intensity
+	Previous  *Intensity
+	Err       error
+}
+
+func (we *WatchEvent) String() string {
+	switch we.Type {
+	case EventError:
+		return fmt.Sprintf("%s: %s", we.Type, we.Err)
+	case EventForecastChanged:
+		return fmt.Sprintf("%s: %v (was %d)", we.Type, we.Intensity, we.Previous.Forecast)
+	}
+
+	return fmt.Sprintf("%s: %v", we.Type, we.Intensity)
+}
+
+// WatchConfig controls what Watch polls for, and how often
+//
+// The API is polled every PollInterval, and also as soon as each settlement period starts. Each poll fetches the settlement
+// periods from Lookback before the current time to Lookahead after it, which decides how long after a period ends its Actual
+// value is watched for, and how far ahead forecasts are watched. Together they must be no more than 30 days.
+//
+// An EventForecastChanged is only sent for changes of more than ForecastThreshold (in gCO2/KWh) from the forecast last sent
+// for the period, so 0 sends every change.
+//
+// After an error the next poll is delayed by PollInterval, doubling for each consecutive error up to MaxBackoff.
+// If the API asks for a longer delay (with a Retry-After header) that is used instead.
+//
+// Zero values are replaced by those of DefaultWatchConfig.
+type WatchConfig struct {
+	PollInterval      time.Duration
+	Lookback          time.Duration
+	Lookahead         time.Duration
+	ForecastThreshold int
+	MaxBackoff        time.Duration
+
+	// now returns the current time, time.Now if it is nil
+	now func() time.Time
+}
+
+// DefaultWatchConfig is a reasonable WatchConfig for use with the national grid carbon intensity API server
+var DefaultWatchConfig = WatchConfig{
+	PollInterval: 5 * time.Minute,
+	Lookback:     24 * time.Hour,
+	Lookahead:    24 * time.Hour,
+	MaxBackoff:   30 * time.Minute,
+}
+
+func (wc WatchConfig) withDefaults() WatchConfig {
+	if wc.PollInterval <= 0 {
+		wc.PollInterval = DefaultWatchConfig.PollInterval
+	}
+
+	if wc.Lookback <= 0 {
+		wc.Lookback = DefaultWatchConfig.Lookback
+	}
+
+	if wc.Lookahead <= 0 {
+		wc.Lookahead = DefaultWatchConfig.Lookahead
+	}
+
+	if wc.MaxBackoff <= 0 {
+		wc.MaxBackoff = DefaultWatchConfig.MaxBackoff
+	}
+
+	if wc.now == nil {
+		wc.now = time.Now
+	}
+
+	return wc
+}
+
+// watcher holds what Watch has seen of the periods being watched
+type watcher struct {
+	config  WatchConfig
+	events  chan<- *WatchEvent
+	current time.Time
+	// The latest data for each period, and the forecast last sent for it (by From)
+	periods   map[time.Time]*Intensity
+	forecasts map[time.Time]*Intensity
+}
+
+// Watch polls the API, sending an event on the returned channel whenever a new settlement period starts, the Actual value of
+// a settlement period is published, or the Forecast of an upcoming settlement period changes; see WatchConfig.
+//
+// The first poll sends an EventNewPeriod for the current settlement period, and is used as the starting point for other events.
+// Errors are sent as EventError events, and polling continues after a backoff.
+//
+// Events must be received promptly, as polling waits for each to be received. Watch stops when ctx is cancelled,
+// closing the channel once any request in progress has been cancelled.
+//
+// If Lookback and Lookahead (after defaults are applied) add up to more than 30 days, an error wrapping ErrRangeTooLarge is
+// returned straight away, as every poll would fail.
+func (ah *APIHandler) Watch(ctx context.Context, config WatchConfig) (<-chan *WatchEvent, error) {
+	config = config.withDefaults()
+	if config.Lookback+config.Lookahead > maxDateRange {
+		return nil, fmt.Errorf("%w; Lookback (%s) and Lookahead (%s) must add up to no more than 30 days", ErrRangeTooLarge,
+			config.Lookback, config.Lookahead)
+	}
+
+	events := make(chan *WatchEvent)
+	w := &watcher{
+		config:    config,
+		events:    events,
+		periods:   make(map[time.Time]*Intensity),
+		forecasts: make(map[time.Time]*Intensity),
+	}
+
+	go func() {
+		defer close(events)
+		w.run(ctx, ah)
+	}()
+
+	return events, nil
+}
+
+func (w *watcher) run(ctx context.Context, ah *APIHandler) {
+	failures := 0
+	for {
+		now := w.config.now()
+		entries, err := ah.GetIntensityBetweenContext(ctx, now.Add(-w.config.Lookback), now.Add(w.config.Lookahead))
+		if ctx.Err() != nil {
+			return
+		}
+
+		var delay time.Duration
+		if err != nil {
+			failures++
+			delay = w.backoff(failures, err)
+			if !w.send(ctx, &WatchEvent{Type: EventError, Err: err}) {
+				return
+			}
+		} else {
+			failures = 0
+			if !w.update(ctx, now, entries) {
+				return
+			}
+
+			delay = w.untilNextPoll(w.config.now())
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns how long to wait after failures consecutive failed polls, the last failing with err
+func (w *watcher) backoff(failures int, err error) time.Duration {
+	delay := w.config.PollInterval
+	for i := 1; i < failures && delay < w.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > w.config.MaxBackoff {
+		delay = w.config.MaxBackoff
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > delay {
+		delay = apiErr.RetryAfter
+	}
+
+	return delay
+}
+
+// untilNextPoll returns how long to wait before the next poll; PollInterval, or until just after the next settlement period
+// starts if that is sooner (allowing a little time for the API to publish it)
+func (w *watcher) untilNextPoll(now time.Time) time.Duration {
+	const publishDelay = 30 * time.Second
+
+	untilNextPeriod := now.Truncate(settlementPeriodDuration).Add(settlementPeriodDuration + publishDelay).Sub(now)
+	if untilNextPeriod < w.config.PollInterval {
+		return untilNextPeriod
+	}
+
+	return w.config.PollInterval
+}
+
+// update compares entries, fetched at now, with those fetched before, sending events for the changes.
+// It returns false if ctx was cancelled while sending.
+func (w *watcher) update(ctx context.Context, now time.Time, entries []*Intensity) bool {
+	first := len(w.periods) == 0
+
+	for _, entry := range entries {
+		previous, seen := w.periods[entry.From]
+		w.periods[entry.From] = entry
+
+		if !entry.From.After(now) && entry.To.After(now) && !entry.From.Equal(w.current) {
+			w.current = entry.From
+			if !w.send(ctx, &WatchEvent{Type: EventNewPeriod, Intensity: entry}) {
+				return false
+			}
+		}
+
+		if first || !seen {
+			w.forecasts[entry.From] = entry
+			continue
+		}
+
+		if !entry.To.After(now) && entry.HasActual() && !previous.HasActual() {
+			if !w.send(ctx, &WatchEvent{Type: EventActualPublished, Intensity: entry}) {
+				return false
+			}
+		}
+
+		last := w.forecasts[entry.From]
+		forecast, known := entry.ForecastValue()
+		lastForecast, lastKnown := last.ForecastValue()
+		if !lastKnown {
+			w.forecasts[entry.From] = entry
+			continue
+		}
+
+		if entry.From.After(now) && known && abs(forecast-lastForecast) > w.config.ForecastThreshold {
+			w.forecasts[entry.From] = entry
+			if !w.send(ctx, &WatchEvent{Type: EventForecastChanged, Intensity: entry, Previous: last}) {
+				return false
+			}
+		}
+	}
+
+	// Forget periods which have dropped out of the range being watched
+	for from := range w.periods {
+		if from.Before(now.Add(-w.config.Lookback - settlementPeriodDuration)) {
+			delete(w.periods, from)
+			delete(w.forecasts, from)
+		}
+	}
+
+	return true
+}
+
+// send sends event, returning false if ctx was cancelled first
+func (w *watcher) send(ctx context.Context, event *WatchEvent) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
@@ -0,0 +1,166 @@
+package carbonintensity
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newWatchTestServer returns a test server giving each period the forecast in forecasts, or 200, and the actual in actuals if
+// there is one. The maps may be changed with update while it is running.
+func newWatchTestServer(forecasts map[time.Time]int, actuals map[time.Time]int) *testPeriodServer {
+	return startTestPeriodServer(&testPeriodServer{
+		forecast: func(from time.Time) int {
+			if forecast, ok := forecasts[from]; ok {
+				return forecast
+			}
+
+			return 200
+		},
+		actual: func(from time.Time) (int, bool) {
+			actual, ok := actuals[from]
+			return actual, ok
+		},
+	})
+}
+
+func receiveEvent(t *testing.T, events <-chan *WatchEvent) *WatchEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+		return nil
+	}
+}
+
+func TestWatch(t *testing.T) {
+	forecasts := make(map[time.Time]int)
+	actuals := make(map[time.Time]int)
+	server := newWatchTestServer(forecasts, actuals)
+	defer server.Close()
+
+	var clockMutex sync.Mutex
+	now := time.Date(2018, 1, 20, 12, 10, 0, 0, time.UTC)
+	setNow := func(t time.Time) {
+		clockMutex.Lock()
+		defer clockMutex.Unlock()
+		now = t
+	}
+
+	config := WatchConfig{
+		PollInterval:      5 * time.Millisecond,
+		Lookback:          2 * time.Hour,
+		Lookahead:         2 * time.Hour,
+		ForecastThreshold: 10,
+		now: func() time.Time {
+			clockMutex.Lock()
+			defer clockMutex.Unlock()
+			return now
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := NewCarbonIntensityAPIHandler(WithBaseURL(server.URL)).Watch(ctx, config)
+	if !assert.NoError(t, err) {
+		cancel()
+		return
+	}
+
+	event := receiveEvent(t, events)
+	assert.Equal(t, EventNewPeriod, event.Type)
+	assert.Equal(t, time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC), event.Intensity.From)
+
+	// Actual values published for past periods
+	past := time.Date(2018, 1, 20, 11, 30, 0, 0, time.UTC)
+	server.update(func() { actuals[past] = 180 })
+
+	event = receiveEvent(t, events)
+	assert.Equal(t, EventActualPublished, event.Type)
+	assert.Equal(t, past, event.Intensity.From)
+	assert.Equal(t, 180, event.Intensity.Actual)
+
+	// Forecast changes within the threshold aren't sent, but add up
+	upcoming := time.Date(2018, 1, 20, 13, 0, 0, 0, time.UTC)
+	server.update(func() { forecasts[upcoming] = 205 })
+	server.waitForRequests(server.requestCount() + 2)
+	server.update(func() { forecasts[upcoming] = 211 })
+
+	event = receiveEvent(t, events)
+	assert.Equal(t, EventForecastChanged, event.Type)
+	assert.Equal(t, upcoming, event.Intensity.From)
+	assert.Equal(t, 211, event.Intensity.Forecast)
+	assert.Equal(t, 200, event.Previous.Forecast)
+
+	// New settlement periods
+	setNow(time.Date(2018, 1, 20, 12, 31, 0, 0, time.UTC))
+
+	event = receiveEvent(t, events)
+	assert.Equal(t, EventNewPeriod, event.Type)
+	assert.Equal(t, time.Date(2018, 1, 20, 12, 30, 0, 0, time.UTC), event.Intensity.From)
+
+	// Errors are sent, and polling continues
+	server.update(func() { server.failures = -1 })
+
+	event = receiveEvent(t, events)
+	assert.Equal(t, EventError, event.Type)
+
+	var apiErr *APIError
+	assert.True(t, errors.As(event.Err, &apiErr))
+
+	server.update(func() {
+		server.failures = 0
+		actuals[past.Add(30*time.Minute)] = 190
+	})
+
+	for event.Type == EventError {
+		event = receiveEvent(t, events)
+	}
+
+	assert.Equal(t, EventActualPublished, event.Type)
+	assert.Equal(t, 190, event.Intensity.Actual)
+
+	// The channel is closed once cancelled
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchRangeTooLarge(t *testing.T) {
+	handler := NewCarbonIntensityAPIHandler(WithBaseURL("http://127.0.0.1:0"))
+
+	// Rejected before any polling, so the channel is never created
+	events, err := handler.Watch(context.Background(), WatchConfig{Lookback: 20 * 24 * time.Hour, Lookahead: 11 * 24 * time.Hour})
+	assert.True(t, errors.Is(err, ErrRangeTooLarge))
+	assert.Nil(t, events)
+
+	// Defaults are applied first
+	_, err = handler.Watch(context.Background(), WatchConfig{Lookback: 30 * 24 * time.Hour})
+	assert.True(t, errors.Is(err, ErrRangeTooLarge))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	events, err = handler.Watch(ctx, WatchConfig{Lookback: 29 * 24 * time.Hour})
+	assert.NoError(t, err)
+	for range events {
+	}
+}
+
+func TestWatchBackoff(t *testing.T) {
+	w := &watcher{config: WatchConfig{PollInterval: time.Second, MaxBackoff: 10 * time.Second}.withDefaults()}
+
+	assert.Equal(t, time.Second, w.backoff(1, errors.New("failed")))
+	assert.Equal(t, 4*time.Second, w.backoff(3, errors.New("failed")))
+	assert.Equal(t, 10*time.Second, w.backoff(10, errors.New("failed")))
+	assert.Equal(t, time.Minute, w.backoff(1, &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}))
+
+	// Polls are made as soon as a new settlement period starts
+	w.config.PollInterval = 5 * time.Minute
+	assert.Equal(t, 5*time.Minute, w.untilNextPoll(time.Date(2018, 1, 20, 12, 10, 0, 0, time.UTC)))
+	assert.Equal(t, 31*time.Second, w.untilNextPoll(time.Date(2018, 1, 20, 12, 29, 59, 0, time.UTC)))
+}
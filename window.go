@@ -0,0 +1,204 @@
+package carbonintensity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrNoWindow is returned when there is no window with a complete forecast for a job to run in before its deadline
+var ErrNoWindow = errors.New("No window for job before deadline")
+
+// Window is a period of time for a job to run in, with the forecast carbon intensity over it
+type Window struct {
+	Start time.Time
+	End   time.Time
+	// Total is the forecast intensity summed over the window, weighted by the hours of each settlement period it covers.
+	// Units are gCO2/KW (grams of CO2 per kilowatt of load).
+	Total float64
+	// Average is the mean forecast intensity over the window, in gCO2/KWh
+	Average float64
+}
+
+func (w *Window) String() string {
+	return fmt.Sprintf("%s -> %s {total: %.1f, average: %.1f}", w.Start.Format(natGridTimeFormat), w.End.Format(natGridTimeFormat),
+		w.Total, w.Average)
+}
+
+// WindowSchedule is the result of FindGreenestWindow
+type WindowSchedule struct {
+	// Best is the window with the lowest forecast intensity
+	Best *Window
+	// Alternatives are the next best windows, in order, none of which overlap each other or Best
+	Alternatives []*Window
+	// Now is the window starting immediately, or nil if there isn't a complete forecast for it
+	Now *Window
+	// Saving is how much lower the average intensity of Best is than that of Now, in gCO2/KWh (0 if Now is nil).
+	// Multiplying by the power drawn by the job gives the CO2 saved by running in Best.
+	Saving float64
+}
+
+// forecastWindow returns the Window from start to end, or nil if forecast (which is sorted) doesn't cover all of it
+func forecastWindow(forecast []*Intensity, start time.Time, end time.Time) *Window {
+	window := &Window{Start: start, End: end}
+
+	covered := start
+	for _, intensity := range forecast {
+		if !intensity.To.After(covered) {
+			continue
+		}
+
+		if intensity.From.After(covered) || !covered.Before(end) {
+			break
+		}
+
+		value, ok := intensity.ForecastValue()
+		if !ok {
+			return nil
+		}
+
+		overlapEnd := intensity.To
+		if overlapEnd.After(end) {
+			overlapEnd = end
+		}
+
+		window.Total += float64(value) * overlapEnd.Sub(covered).Hours()
+		covered = overlapEnd
+	}
+
+	if covered.Before(end) {
+		return nil
+	}
+
+	window.Average = window.Total / end.Sub(start).Hours()
+	return window
+}
+
+// FindGreenestWindow finds when a job lasting duration should start to have the lowest forecast carbon intensity,
+// using forecast (e.g. from GetNext48HourIntensity) for the settlement periods from now until deadline.
+//
+// The job can start either at now or at the start of any settlement period after it, and must finish by deadline.
+// Only windows which forecast covers completely, with a known Forecast for every settlement period, are considered.
+// As every window lasts duration, ranking by Total or Average gives the same order; ties go to the earliest start.
+// Up to alternatives other windows are returned too, which don't overlap the best or each other.
+//
+// An error wrapping ErrInvalidRange is returned if duration isn't positive, and ErrNoWindow if there aren't any windows.
+func FindGreenestWindow(forecast []*Intensity, now time.Time, duration time.Duration, deadline time.Time, alternatives int) (*WindowSchedule, error) {
+	if duration <= 0 {
+		return nil, fmt.Errorf("%w; job duration %s must be positive", ErrInvalidRange, duration)
+	}
+
+	sorted := append([]*Intensity(nil), forecast...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].From.Before(sorted[j].From) })
+
+	starts := []time.Time{now}
+	for _, intensity := range sorted {
+		if intensity.From.After(now) {
+			starts = append(starts, intensity.From)
+		}
+	}
+
+	schedule := &WindowSchedule{}
+
+	var windows []*Window
+	for _, start := range starts {
+		end := start.Add(duration)
+		if end.After(deadline) {
+			break
+		}
+
+		window := forecastWindow(sorted, start, end)
+		if window == nil {
+			continue
+		}
+
+		if start.Equal(now) {
+			schedule.Now = window
+		}
+
+		windows = append(windows, window)
+	}
+
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("%w; %s job between %s and %s", ErrNoWindow, duration, now.Format(natGridTimeFormat), deadline.Format(natGridTimeFormat))
+	}
+
+	sort.SliceStable(windows, func(i, j int) bool { return windows[i].Total < windows[j].Total })
+
+	schedule.Best = windows[0]
+	chosen := []*Window{schedule.Best}
+	for _, window := range windows[1:] {
+		if len(schedule.Alternatives) >= alternatives {
+			break
+		}
+
+		overlaps := false
+		for _, other := range chosen {
+			if window.Start.Before(other.End) && other.Start.Before(window.End) {
+				overlaps = true
+				break
+			}
+		}
+
+		if !overlaps {
+			chosen = append(chosen, window)
+			schedule.Alternatives = append(schedule.Alternatives, window)
+		}
+	}
+
+	if schedule.Now != nil {
+		schedule.Saving = schedule.Now.Average - schedule.Best.Average
+	}
+
+	return schedule, nil
+}
+
+// FindGreenestWindowForRegion is the same as FindGreenestWindow, but using a regional forecast
+// (e.g. from GetNext48HourIntensityForPostcode)
+func FindGreenestWindowForRegion(forecast []*RegionalIntensity, now time.Time, duration time.Duration, deadline time.Time, alternatives int) (*WindowSchedule, error) {
+	intensities := make([]*Intensity, 0, len(forecast))
+	for _, regional := range forecast {
+		if regional.Intensity != nil {
+			intensities = append(intensities, regional.Intensity)
+		}
+	}
+
+	return FindGreenestWindow(intensities, now, duration, deadline, alternatives)
+}
+
+// GetGreenestWindow returns when a job lasting duration, which must finish by deadline, should start to have the lowest
+// forecast carbon intensity; see FindGreenestWindow. The forecast used is GetNext48HourIntensity from the current time.
+func (ah *APIHandler) GetGreenestWindow(duration time.Duration, deadline time.Time, alternatives int) (*WindowSchedule, error) {
+	return ah.GetGreenestWindowContext(context.Background(), duration, deadline, alternatives)
+}
+
+// GetGreenestWindowContext is the same as GetGreenestWindow, but the request is made with the context ctx
+func (ah *APIHandler) GetGreenestWindowContext(ctx context.Context, duration time.Duration, deadline time.Time, alternatives int) (*WindowSchedule, error) {
+	now := time.Now()
+
+	forecast, err := ah.GetNext48HourIntensityContext(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return FindGreenestWindow(forecast, now, duration, deadline, alternatives)
+}
+
+// GetGreenestWindowForPostcode is the same as GetGreenestWindow, but using the forecast for the region containing postcode
+func (ah *APIHandler) GetGreenestWindowForPostcode(duration time.Duration, deadline time.Time, alternatives int, postcode string) (*WindowSchedule, error) {
+	return ah.GetGreenestWindowForPostcodeContext(context.Background(), duration, deadline, alternatives, postcode)
+}
+
+// GetGreenestWindowForPostcodeContext is the same as GetGreenestWindowForPostcode, but the request is made with the context ctx
+func (ah *APIHandler) GetGreenestWindowForPostcodeContext(ctx context.Context, duration time.Duration, deadline time.Time, alternatives int, postcode string) (*WindowSchedule, error) {
+	now := time.Now()
+
+	forecast, err := ah.GetNext48HourIntensityForPostcodeContext(ctx, now, postcode)
+	if err != nil {
+		return nil, err
+	}
+
+	return FindGreenestWindowForRegion(forecast, now, duration, deadline, alternatives)
+}
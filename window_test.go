@@ -0,0 +1,106 @@
+package carbonintensity
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// windowForecast returns a forecast of consecutive settlement periods from start, with the given forecast values
+func windowForecast(start time.Time, values ...int) []*Intensity {
+	var forecast []*Intensity
+	for i, value := range values {
+		from := start.Add(time.Duration(i) * 30 * time.Minute)
+		forecast = append(forecast, &Intensity{From: from, To: from.Add(30 * time.Minute), Forecast: value, Actual: -1})
+	}
+
+	return forecast
+}
+
+func TestFindGreenestWindow(t *testing.T) {
+	start := time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC)
+	forecast := windowForecast(start, 200, 180, 100, 120, 300, 90, 80, 250)
+
+	schedule, err := FindGreenestWindow(forecast, start, time.Hour, start.Add(4*time.Hour), 2)
+	assert.Nil(t, err)
+
+	// 90 and 80 (periods 5 and 6) are the lowest pair
+	assert.Equal(t, start.Add(150*time.Minute), schedule.Best.Start)
+	assert.Equal(t, start.Add(210*time.Minute), schedule.Best.End)
+	assert.InDelta(t, 85, schedule.Best.Total, 0.001)
+	assert.InDelta(t, 85, schedule.Best.Average, 0.001)
+
+	// 100 and 120 are next best, then 200 and 180 as every other window overlaps
+	if assert.Equal(t, 2, len(schedule.Alternatives)) {
+		assert.Equal(t, start.Add(60*time.Minute), schedule.Alternatives[0].Start)
+		assert.InDelta(t, 110, schedule.Alternatives[0].Average, 0.001)
+		assert.Equal(t, start, schedule.Alternatives[1].Start)
+	}
+
+	if assert.NotNil(t, schedule.Now) {
+		assert.Equal(t, start, schedule.Now.Start)
+		assert.InDelta(t, 190, schedule.Now.Average, 0.001)
+	}
+
+	assert.InDelta(t, 105, schedule.Saving, 0.001)
+}
+
+func TestFindGreenestWindowPartialPeriods(t *testing.T) {
+	start := time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC)
+	forecast := windowForecast(start, 300, 200, 100, 50)
+
+	// Starting part way through the first period, a 45 minute job covers 20 minutes at 300 and 25 minutes at 200
+	now := start.Add(10 * time.Minute)
+	schedule, err := FindGreenestWindow(forecast, now, 45*time.Minute, start.Add(2*time.Hour), 0)
+	assert.Nil(t, err)
+
+	if assert.NotNil(t, schedule.Now) {
+		assert.InDelta(t, (300*20+200*25)/60.0, schedule.Now.Total, 0.001)
+		assert.InDelta(t, (300*20+200*25)/45.0, schedule.Now.Average, 0.001)
+	}
+
+	// Starting at 13:30 would overrun the deadline, so 13:00 is best
+	assert.Equal(t, start.Add(60*time.Minute), schedule.Best.Start)
+	assert.InDelta(t, (100*30+50*15)/45.0, schedule.Best.Average, 0.001)
+	assert.Equal(t, 0, len(schedule.Alternatives))
+}
+
+func TestFindGreenestWindowIncompleteForecast(t *testing.T) {
+	start := time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC)
+	forecast := windowForecast(start, 100, -1, 300, 50, 60, 70)
+
+	// Remove the period starting at 13:30, leaving a gap
+	forecast = append(forecast[:3], forecast[4:]...)
+
+	schedule, err := FindGreenestWindow(forecast, start, time.Hour, start.Add(3*time.Hour), 5)
+	assert.Nil(t, err)
+	assert.Nil(t, schedule.Now)
+	assert.Equal(t, 0.0, schedule.Saving)
+	assert.Equal(t, start.Add(120*time.Minute), schedule.Best.Start)
+	assert.Equal(t, 0, len(schedule.Alternatives))
+
+	_, err = FindGreenestWindow(forecast, start, 3*time.Hour, start.Add(3*time.Hour), 5)
+	assert.True(t, errors.Is(err, ErrNoWindow))
+
+	_, err = FindGreenestWindow(forecast, start, time.Hour, start.Add(30*time.Minute), 5)
+	assert.True(t, errors.Is(err, ErrNoWindow))
+
+	_, err = FindGreenestWindow(forecast, start, 0, start.Add(3*time.Hour), 5)
+	assert.True(t, errors.Is(err, ErrInvalidRange))
+}
+
+func TestFindGreenestWindowForRegion(t *testing.T) {
+	start := time.Date(2018, 1, 20, 12, 0, 0, 0, time.UTC)
+
+	var forecast []*RegionalIntensity
+	for _, intensity := range windowForecast(start, 300, 100, 200) {
+		forecast = append(forecast, &RegionalIntensity{RegionID: 3, Intensity: intensity})
+	}
+
+	schedule, err := FindGreenestWindowForRegion(forecast, start, 30*time.Minute, start.Add(2*time.Hour), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, start.Add(30*time.Minute), schedule.Best.Start)
+	assert.Equal(t, start.Add(60*time.Minute), schedule.Alternatives[0].Start)
+}